@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"yuki_zpm.org/logger"
 )
 
+// Cache is safe for concurrent use; every access to the index goes through mu.
 type Cache struct {
 	cacheDir string
+	mu       sync.Mutex
 	entries  map[string]Entry
 }
 
@@ -18,6 +21,8 @@ type Entry struct {
 	Checksum string `json:"checksum"`
 	Version  string `json:"version"`
 	CommitSHA string 
+	// ZigHash is missing from entries written by older versions of yuki.
+	ZigHash  string `json:"zig_hash,omitempty"`
 }
 
 func New() *Cache {
@@ -42,6 +47,9 @@ func (c *Cache) GetCacheDir() string {
 }
 
 func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	entry, exists := c.entries[key]
 	if !exists {
 		return Entry{}, false
@@ -57,16 +65,25 @@ func (c *Cache) Get(key string) (Entry, bool) {
 }
 
 func (c *Cache) Set(key string, entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.entries[key] = entry
 	c.save()
 }
 
 func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	delete(c.entries, key)
 	c.save()
 }
 
 func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.entries = make(map[string]Entry)
 	
 	if err := os.RemoveAll(c.cacheDir); err != nil {
@@ -133,6 +150,9 @@ func (c *Cache) GetSize() (int64, error) {
 }
 
 func (c *Cache) ListEntries() map[string]Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	result := make(map[string]Entry)
 	for k, v := range c.entries {
 		result[k] = v
@@ -2,13 +2,15 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
-	"yuki_zpm.org/fetch"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
 	"yuki_zpm.org/resolver"
+	"yuki_zpm.org/workspace"
 )
 
 func AddCmd() *cobra.Command {
@@ -18,7 +20,7 @@ func AddCmd() *cobra.Command {
 	var rootFile string
 	var branch string
 	cmd := &cobra.Command{
-		Use:   "add <package>[@version]",
+		Use:   "add <package>[@version] | <path>",
 		Short: "Add a dependency to the project",
 		Long:  "Add a dependency to the project manifest after validation. Use 'yuki install' to install the dependency.",
 		Args:  cobra.ExactArgs(1),
@@ -36,9 +38,11 @@ func AddCmd() *cobra.Command {
 }
 
 func parsePackageSpec(packageSpec string) (name, version, gitURL string, isLatestCommit bool, err error) {
-	parts := strings.Split(packageSpec, "@")
-	if len(parts) > 2 {
-		return "", "", "", false, fmt.Errorf("invalid package specification format")
+	// SSH URLs contain an '@' of their own, so only an '@' after the last
+	// path separator starts the version.
+	parts := []string{packageSpec}
+	if at := strings.LastIndex(packageSpec, "@"); at > strings.LastIndexAny(packageSpec, "/:") {
+		parts = []string{packageSpec[:at], packageSpec[at+1:]}
 	}
 
 	packageURL := parts[0]
@@ -62,20 +66,15 @@ func parsePackageSpec(packageSpec string) (name, version, gitURL string, isLates
 		return "", "", "", false, fmt.Errorf("package must be in 'username/repo' format")
 	}
 
-	if !strings.HasPrefix(packageURL, "http") {
-		gitURL = fmt.Sprintf("https://github.com/%s", packageURL)
-	} else {
+	if strings.Contains(packageURL, "://") || strings.Contains(packageURL, "@") {
 		gitURL = packageURL
+	} else {
+		gitURL = fmt.Sprintf("https://github.com/%s", packageURL)
 	}
 
-	if strings.HasPrefix(gitURL, "https://github.com/") {
-		parts := strings.Split(strings.TrimPrefix(gitURL, "https://github.com/"), "/")
-		if len(parts) >= 2 {
-			name = parts[1]
-			if strings.HasSuffix(name, ".git") {
-				name = name[:len(name)-4]
-			}
-		}
+	segments := strings.FieldsFunc(gitURL, func(r rune) bool { return r == '/' || r == ':' })
+	if len(segments) >= 2 {
+		name = strings.TrimSuffix(segments[len(segments)-1], ".git")
 	}
 
 	if name == "" {
@@ -85,6 +84,52 @@ func parsePackageSpec(packageSpec string) (name, version, gitURL string, isLates
 	return name, version, gitURL, isLatestCommit, nil
 }
 
+// isLocalPackage reports whether a package specification names a directory,
+// e.g. "../libs/parser", rather than a repository.
+func isLocalPackage(packageSpec string) bool {
+	if !strings.HasPrefix(packageSpec, ".") && !filepath.IsAbs(packageSpec) {
+		return false
+	}
+	info, err := os.Stat(packageSpec)
+	return err == nil && info.IsDir()
+}
+
+// localPackageName returns the name declared in a local package's yuki.toml,
+// falling back to the directory name.
+func localPackageName(dir string) string {
+	if m, err := manifest.Load(dir); err == nil && m.Package.Name != "" {
+		return m.Package.Name
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.Base(dir)
+	}
+	return filepath.Base(abs)
+}
+
+// memberRelativePath turns a path given on the command line into one relative
+// to the directory of the package it is added to.
+func memberRelativePath(memberDir, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path)), nil
+	}
+
+	absMember, err := filepath.Abs(memberDir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absMember, absPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
 func existsInDependencies(m *manifest.Manifest, name string) bool {
 	if m.Dependencies != nil {
 		if _, exists := m.Dependencies[name]; exists {
@@ -105,17 +150,42 @@ func existsInDependencies(m *manifest.Manifest, name string) bool {
 }
 
 func runAdd(packageSpec string, dev, build bool, alias, rootFile, branch string) error {
-	cwd := "."
-
-	m, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
 	}
 
-	packageName, version, gitURL, isLatestCommit, err := parsePackageSpec(packageSpec)
+	member, err := project.Single()
 	if err != nil {
-		return fmt.Errorf("invalid package specification: %w", err)
+		return err
+	}
+	m := member.Manifest
+
+	var packageName, version string
+	var isLatestCommit bool
+	var dep manifest.Dependency
+
+	if isLocalPackage(packageSpec) {
+		if branch != "" {
+			return fmt.Errorf("--branch cannot be used with a path dependency")
+		}
+		packageName = localPackageName(packageSpec)
+		path, err := memberRelativePath(member.Dir, packageSpec)
+		if err != nil {
+			return fmt.Errorf("invalid path '%s': %w", packageSpec, err)
+		}
+		dep = manifest.Dependency{Path: path}
+		logger.Info("Using local package at %s", dep.Path)
+	} else {
+		var gitURL string
+		packageName, version, gitURL, isLatestCommit, err = parsePackageSpec(packageSpec)
+		if err != nil {
+			return fmt.Errorf("invalid package specification: %w", err)
+		}
+		dep = manifest.Dependency{
+			Git:     gitURL,
+			Version: version,
+		}
 	}
 
 	dependencyName := packageName
@@ -124,10 +194,6 @@ func runAdd(packageSpec string, dev, build bool, alias, rootFile, branch string)
 		logger.Info("Using alias '%s' for package '%s'", alias, packageName)
 	}
 	logger.Info("Validating dependency '%s'...", dependencyName)
-	dep := manifest.Dependency{
-		Git:     gitURL,
-		Version: version,
-	}
 
 	if isLatestCommit {
 		dep.UseLatestCommit = true
@@ -158,26 +224,42 @@ func runAdd(packageSpec string, dev, build bool, alias, rootFile, branch string)
 
 	logger.Info("Checking if dependency can be resolved...")
 	resolverInstance := resolver.New()
+	resolverInstance.SetProjectRoot(project.Root)
+	resolverInstance.SetPatches(project.Patch)
+	resolverInstance.SetZigVersion(localZigVersion(project))
+	if lockFile, err := manifest.LoadLockFile(project.Root); err == nil {
+		resolverInstance.UseLockFile(lockFile)
+	}
 
 	tempManifest := *m
-	if tempManifest.Dependencies == nil {
-		tempManifest.Dependencies = make(map[string]manifest.Dependency)
+	tempManifest.Dependencies = make(map[string]manifest.Dependency, len(m.Dependencies)+1)
+	for name, existing := range m.Dependencies {
+		tempManifest.Dependencies[name] = existing
 	}
 	tempManifest.Dependencies[dependencyName] = dep
 
-	_, err = resolverInstance.Resolve(&tempManifest)
+	members := append([]workspace.Member{}, project.Members...)
+	for i := range members {
+		if members[i].Name == member.Name {
+			members[i].Manifest = &tempManifest
+		}
+	}
+
+	resolution, err := resolverInstance.ResolveWorkspace(members)
 	if err != nil {
 		logger.Error("Failed to resolve dependency '%s': %v", dependencyName, err)
 		return fmt.Errorf("dependency resolution failed: %w", err)
 	}
 	logger.Success("✓ Dependency can be resolved")
 
-	logger.Info("Validating dependency availability...")
-	fetcher := fetch.NewFetcher()
-	fetchResult, err := fetcher.FetchDependency(dependencyName, dep)
-	if err != nil {
-		logger.Error("Failed to fetch dependency '%s': %v", dependencyName, err)
-		return fmt.Errorf("dependency fetch failed: %w", err)
+	var fetchResult *resolver.ResolvedDependency
+	for i := range resolution.Dependencies {
+		if resolution.Dependencies[i].Name == dependencyName {
+			fetchResult = &resolution.Dependencies[i]
+		}
+	}
+	if fetchResult == nil {
+		return fmt.Errorf("dependency '%s' is missing from the resolution", dependencyName)
 	}
 	logger.Success("✓ Dependency is accessible and valid")
 
@@ -217,7 +299,7 @@ func runAdd(packageSpec string, dev, build bool, alias, rootFile, branch string)
 		logger.Success("Added '%s' as dependency", dependencyName)
 	}
 
-	if err := m.Save(cwd); err != nil {
+	if err := m.Save(member.Dir); err != nil {
 		return fmt.Errorf("failed to save manifest: %w", err)
 	}
 
@@ -7,22 +7,44 @@ import (
 
 func BuildCmd() *cobra.Command {
 	var release bool
+	var locked bool
+	var features featureFlags
 
 	cmd := &cobra.Command{
 		Use:   "build",
 		Short: "Build the project",
 		Long:  "Compile the project with all dependencies",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runBuild(release)
+			return runBuild(release, locked, &features)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&release, "release", "r", false, "Build in release mode")
+	cmd.Flags().BoolVar(&locked, "locked", false, "Fail if yuki.lock is out of date with yuki.toml")
+	features.register(cmd)
 
 	return cmd
 }
 
-func runBuild(release bool) error {
+func runBuild(release, locked bool, features *featureFlags) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	if err := checkLock(project, locked); err != nil {
+		return err
+	}
+
+	if err := applyFeatures(project, features); err != nil {
+		return err
+	}
+
 	builder := build.New()
-	return builder.Build(".", release)
+	for _, member := range project.Selected {
+		if err := builder.Build(member.Dir, release); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,48 +1,61 @@
 package cli
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 	"yuki_zpm.org/logger"
-	"yuki_zpm.org/manifest"
 	"yuki_zpm.org/resolver"
+	"yuki_zpm.org/workspace"
 )
 
 func CheckCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "check",
 		Short: "Verify dependencies and manifest integrity",
 		Long:  "Check that all dependencies can be resolved and manifest is valid",
 		RunE:  runCheck,
 	}
+
+	cmd.Flags().Bool("locked", false, "Fail if yuki.lock is out of date with yuki.toml")
+
+	return cmd
 }
 
 func runCheck(cmd *cobra.Command, args []string) error {
-	cwd := "."
-	
+	locked, _ := cmd.Flags().GetBool("locked")
+
 	logger.Info("Checking manifest and dependencies...")
 
-	m, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
 	}
 
-	if err := m.Validate(); err != nil {
-		logger.Error("Manifest validation failed: %v", err)
-		return err
+	for _, member := range project.Selected {
+		if err := member.Manifest.Validate(); err != nil {
+			logger.Error("Manifest validation failed: %v", err)
+			return err
+		}
 	}
 	logger.Success("Manifest is valid")
 
-	resolver := resolver.New()
-	if err := resolver.ValidateDependencies(m); err != nil {
-		logger.Error("Dependency validation failed: %v", err)
+	if err := checkLock(project, locked); err != nil {
 		return err
 	}
+
+	for _, member := range project.Selected {
+		if err := validateMember(member); err != nil {
+			logger.Error("Dependency validation failed: %v", err)
+			return err
+		}
+	}
 	logger.Success("All dependencies are accessible")
 
-	resolution, err := resolver.Resolve(m)
+	resolver := resolver.New()
+	resolver.SetProjectRoot(project.Root)
+	resolver.SetPatches(project.Patch)
+	resolver.SetZigVersion(localZigVersion(project))
+
+	resolution, err := resolver.ResolveWorkspace(project.Members)
 	if err != nil {
 		logger.Error("Dependency resolution failed: %v", err)
 		return err
@@ -57,3 +70,11 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	logger.Success("All checks passed")
 	return nil
 }
+
+// validateMember checks the dependencies of one package, whose path
+// dependencies are relative to its own directory.
+func validateMember(member workspace.Member) error {
+	validator := resolver.New()
+	validator.SetProjectRoot(member.Dir)
+	return validator.ValidateDependencies(member.Manifest)
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/workspace"
+)
+
+// featureFlags are the --features and --no-default-features flags shared by
+// the commands that install or build a package.
+type featureFlags struct {
+	features          []string
+	noDefaultFeatures bool
+}
+
+func (f *featureFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&f.features, "features", "F", nil, "Comma separated list of features to enable")
+	cmd.Flags().BoolVar(&f.noDefaultFeatures, "no-default-features", false, "Do not enable the default feature")
+}
+
+func (f *featureFlags) resolve(m *manifest.Manifest) (*manifest.FeatureSet, error) {
+	features, err := m.ResolveFeatures(f.features, !f.noDefaultFeatures)
+	if err != nil {
+		return nil, fmt.Errorf("invalid features: %w", err)
+	}
+	return features, nil
+}
+
+// applyFeatures regenerates yuki.zig of the selected packages, and the yuki
+// block of their build.zig if installing added one, for the features chosen
+// on the command line, so the next zig build sees them. The files keep these
+// features until they are generated again. Packages that have not been
+// installed yet are left alone.
+func applyFeatures(project *workspace.Project, flags *featureFlags) error {
+	lockFile, err := manifest.LoadLockFile(project.Root)
+	if err != nil {
+		return fmt.Errorf("failed to load lock file: %w", err)
+	}
+
+	vendorer := vendor.New()
+	for _, member := range project.Selected {
+		if _, err := os.Stat(filepath.Join(member.Dir, vendor.YukiZigFile)); err != nil {
+			continue
+		}
+
+		features, err := flags.resolve(member.Manifest)
+		if err != nil {
+			return err
+		}
+
+		memberLock := lockFile.Subset(member.Manifest.DependencyNames())
+		if err := vendorer.GenerateYukiZig(member.Dir, memberLock, member.Manifest, features); err != nil {
+			return fmt.Errorf("failed to generate yuki.zig: %w", err)
+		}
+
+		if vendorer.ManagesBuildZig(member.Dir) {
+			if err := vendorer.UpdateBuildZig(member.Dir, memberLock, member.Manifest, features); err != nil {
+				return fmt.Errorf("failed to update build.zig: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
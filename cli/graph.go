@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"path/filepath"
+	"sort"
+
+	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/manifest"
+)
+
+// depEdge is a dependent asking for one package.
+type depEdge struct {
+	name string
+	// kind is manifest.KindNormal, KindDev or KindBuild. Target specific
+	// edges use the target spec instead, e.g. "os=linux" or "dev os=linux".
+	kind string
+	// dep is the manifest entry of the dependent. known is false when the
+	// dependent's yuki.toml could not be read, e.g. it is not vendored.
+	dep   manifest.Dependency
+	known bool
+}
+
+// depGraph is the locked dependency graph of a project. Edges from members
+// come from their manifests; edges between packages are the dependencies
+// recorded in yuki.lock, with requirements read from the vendored manifests.
+type depGraph struct {
+	root      string
+	packages  map[string]manifest.LockedPackage
+	manifests map[string]*manifest.Manifest
+}
+
+func newDepGraph(root string, lockFile *manifest.LockFile) *depGraph {
+	g := &depGraph{
+		root:      root,
+		packages:  make(map[string]manifest.LockedPackage),
+		manifests: make(map[string]*manifest.Manifest),
+	}
+	for _, pkg := range lockFile.Package {
+		g.packages[pkg.Name] = pkg
+	}
+	return g
+}
+
+// locked returns the yuki.lock entry of a package.
+func (g *depGraph) locked(name string) (manifest.LockedPackage, bool) {
+	pkg, exists := g.packages[name]
+	return pkg, exists
+}
+
+// sortedPackages returns every locked package ordered by name.
+func (g *depGraph) sortedPackages() []manifest.LockedPackage {
+	packages := make([]manifest.LockedPackage, 0, len(g.packages))
+	for _, pkg := range g.packages {
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// memberEdges returns the dependencies a member declares, sorted by kind and
+// then by name.
+func (g *depGraph) memberEdges(m *manifest.Manifest) []depEdge {
+	var edges []depEdge
+	add := func(deps map[string]manifest.Dependency, kind string) {
+		for _, name := range manifest.SortedDependencyNames(deps) {
+			edges = append(edges, depEdge{name: name, kind: kind, dep: deps[name], known: true})
+		}
+	}
+
+	add(m.Dependencies, manifest.KindNormal)
+	add(m.DevDeps, manifest.KindDev)
+	add(m.BuildDeps, manifest.KindBuild)
+	for _, spec := range m.TargetNames() {
+		target := m.Target[spec]
+		add(target.Dependencies, spec)
+		add(target.DevDeps, manifest.KindDev+" "+spec)
+		add(target.BuildDeps, manifest.KindBuild+" "+spec)
+	}
+	return edges
+}
+
+// packageEdges returns the dependencies yuki.lock records for a package.
+func (g *depGraph) packageEdges(name string) []depEdge {
+	pkg, exists := g.packages[name]
+	if !exists {
+		return nil
+	}
+
+	m := g.manifest(name)
+	var edges []depEdge
+	for _, depName := range pkg.Deps {
+		edge := depEdge{name: depName, kind: manifest.KindNormal}
+		if m != nil {
+			edge.dep, edge.known = m.RuntimeDependencies()[depName]
+			if m.DependencyTargets(depName) != nil {
+				edge.kind = targetKind(m, depName)
+			}
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// manifest returns the yuki.toml of a vendored package, or nil.
+func (g *depGraph) manifest(name string) *manifest.Manifest {
+	if m, loaded := g.manifests[name]; loaded {
+		return m
+	}
+
+	var m *manifest.Manifest
+	dir := filepath.Join(g.root, vendor.VendorDir, name)
+	if manifest.Exists(dir) {
+		if loaded, err := manifest.Load(dir); err == nil {
+			m = loaded
+		}
+	}
+	g.manifests[name] = m
+	return m
+}
+
+// targetKind names the target tables a package declares a dependency in.
+func targetKind(m *manifest.Manifest, name string) string {
+	kind := ""
+	for _, spec := range m.TargetNames() {
+		if _, exists := m.Target[spec].Dependencies[name]; exists {
+			if kind != "" {
+				kind += " | "
+			}
+			kind += spec
+		}
+	}
+	return kind
+}
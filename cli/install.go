@@ -4,120 +4,303 @@ import (
 	"fmt"
     "os/exec"
     "os"
+    "path/filepath"
+    "runtime"
+    "sync"
     
 	"github.com/spf13/cobra"
-	"yuki_zpm.org/fetch"
+	"yuki_zpm.org/integrity"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
 	"yuki_zpm.org/resolver"
 	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/source"
+	"yuki_zpm.org/workspace"
 )
 
 func InstallCmd() *cobra.Command {
+	var features featureFlags
+
 	cmd := &cobra.Command{
 		Use:   "install",
 		Short: "Install all dependencies",
-		Long:  "Install all dependencies according to the manifest and lock file",
-		RunE:  runInstall,
+		Long:  "Install all dependencies according to the manifest and lock file.\n\nWith --mode=zon nothing is vendored: the direct dependencies are written to the .dependencies of build.zig.zon instead, with their locked URL and package hash, for build.zig to use with b.dependency(). Zig fetches their own dependencies as their build.zig.zon says.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInstall(cmd, &features)
+		},
 	}
 	
 	cmd.Flags().Bool("skip-build-update", false, "Skip updating build.zig with dependencies")
+	cmd.Flags().Bool("locked", false, "Fail if yuki.lock would need to be updated")
+	cmd.Flags().Bool("frozen", false, "Like --locked, and also forbid any network access")
+	cmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of packages to resolve and fetch in parallel")
+	cmd.Flags().StringSlice("update-checksum", nil, "Accept new contents for these packages when their tag moved upstream")
+	cmd.Flags().String("mode", installModeVendor, "How to install dependencies: 'vendor' into yuki_modules, or 'zon' into build.zig.zon")
+	features.register(cmd)
 	
 	return cmd
 }
 
-func runInstall(cmd *cobra.Command, args []string) error {
-	cwd := "."
+func runInstall(cmd *cobra.Command, features *featureFlags) error {
 	skipBuildUpdate, _ := cmd.Flags().GetBool("skip-build-update")
+	locked, _ := cmd.Flags().GetBool("locked")
+	frozen, _ := cmd.Flags().GetBool("frozen")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	updateChecksum, _ := cmd.Flags().GetStringSlice("update-checksum")
+	mode, _ := cmd.Flags().GetString("mode")
+	if frozen {
+		locked = true
+	}
+	if mode != installModeVendor && mode != installModeZon {
+		return fmt.Errorf("invalid --mode '%s': expected '%s' or '%s'", mode, installModeVendor, installModeZon)
+	}
 
-	m, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
+	}
+	root := project.Root
+
+	// Check the requested features before doing any work.
+	for _, member := range project.Selected {
+		if _, err := features.resolve(member.Manifest); err != nil {
+			return err
+		}
+	}
+
+	existingLock, err := manifest.LoadLockFile(root)
+	if err != nil {
+		return fmt.Errorf("failed to load lock file: %w", err)
 	}
 
 	logger.Info("Installing dependencies...")
 
 	resolver := resolver.New()
-	resolution, err := resolver.Resolve(m)
+	resolver.SetProjectRoot(root)
+	resolver.SetPatches(project.Patch)
+	zigVersion := localZigVersion(project)
+	resolver.SetZigVersion(zigVersion)
+	resolver.UseLockFile(existingLock)
+	resolver.UseVendored(root)
+	resolver.SetOffline(frozen)
+	resolver.SetJobs(jobs)
+	if err := updateChecksums(resolver, existingLock, updateChecksum); err != nil {
+		return err
+	}
+
+	resolution, err := resolver.ResolveWorkspace(project.Members)
 	if err != nil {
 		return fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
 
+	lockFile := resolution.LockFile()
+	lockFile.Metadata.Fingerprint = project.Fingerprint()
+
+	if locked {
+		if changes := manifest.DiffLockFiles(existingLock, lockFile); len(changes) > 0 {
+			logger.Error("yuki.lock is out of date:")
+			for _, change := range changes {
+				logger.Error("  %s", change)
+			}
+			return fmt.Errorf("yuki.lock needs to be updated but --locked was passed")
+		}
+	}
+
+	vendorer := vendor.New()
+
+	if mode == installModeZon {
+		return installZon(vendorer, project, resolution, lockFile, zigVersion)
+	}
+
 	if len(resolution.Dependencies) == 0 {
 		logger.Info("No dependencies to install")
+	}
+
+	if err := vendorDependencies(vendorer, root, resolution.Dependencies, jobs); err != nil {
+		return err
+	}
+	
+	if err := lockFile.Save(root); err != nil {
+		return fmt.Errorf("failed to save lock file: %w", err)
+	}
+
+	// yuki.zig is still needed to export the features. Without them only
+	// the files yuki generated before are updated, to drop the removed
+	// dependencies.
+	if len(resolution.Dependencies) == 0 && !declaresFeatures(project.Selected) {
+		for _, member := range project.Selected {
+			if skipBuildUpdate || !vendorer.ManagesBuildZig(member.Dir) {
+				continue
+			}
+			if err := updateMemberFiles(vendorer, project, member, lockFile, features, skipBuildUpdate); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 
-	fetcher := fetch.NewFetcher()
-	vendorer := vendor.New()
+	for _, member := range project.Selected {
+		if project.Workspace {
+			logger.Info("Generating build files for '%s'...", member.Name)
+		}
+		if err := updateMemberFiles(vendorer, project, member, lockFile, features, skipBuildUpdate); err != nil {
+			return err
+		}
+	}
 
-	lockFile := &manifest.LockFile{
-		Metadata: manifest.LockMetadata{Version: "1"},
-		Package:  []manifest.LockedPackage{},
+	logger.Success("Successfully installed %d dependencies", len(resolution.Dependencies))
+	if !skipBuildUpdate {
+		logger.Info("Dependencies have been automatically added to build.zig")
 	}
+	
+	return nil
+}
 
+// Values of install --mode.
+const (
+	installModeVendor = "vendor"
+	installModeZon    = "zon"
+)
+
+// installZon saves the lock file and writes the direct dependencies of each
+// selected member to its build.zig.zon, leaving fetching and building to Zig.
+func installZon(vendorer *vendor.Vendorer, project *workspace.Project, resolution *resolver.Resolution, lockFile *manifest.LockFile, zigVersion string) error {
+	if err := lockFile.Save(project.Root); err != nil {
+		return fmt.Errorf("failed to save lock file: %w", err)
+	}
+
+	resolved := make(map[string]resolver.ResolvedDependency, len(resolution.Dependencies))
 	for _, dep := range resolution.Dependencies {
-		logger.Info("Installing '%s'", dep.Name)
-		
-		var manifestDep manifest.Dependency
-		found := false
-		
-		if d, exists := m.Dependencies[dep.Name]; exists {
-			manifestDep = d
-			found = true
-		} else if d, exists := m.DevDeps[dep.Name]; exists {
-			manifestDep = d
-			found = true
-		} else if d, exists := m.BuildDeps[dep.Name]; exists {
-			manifestDep = d
-			found = true
-		}
-		
-		if !found {
-			logger.Warn("Dependency '%s' not found in manifest, skipping", dep.Name)
+		resolved[dep.Name] = dep
+	}
+
+	written := 0
+	for _, member := range project.Selected {
+		deps, err := zonDependencies(member.Dir, member.Manifest.DependencyNames(), lockFile, resolved)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Updating build.zig.zon of '%s' with %d dependencies...", member.Name, len(deps))
+		if err := vendorer.UpdateBuildZigZon(member.Dir, member.Manifest, deps, zigVersion); err != nil {
+			return err
+		}
+		written += len(deps)
+	}
+
+	logger.Success("Successfully wrote %d dependencies to build.zig.zon", written)
+	logger.Info("Use them in build.zig with b.dependency(\"name\", .{})")
+	return nil
+}
+
+// zonDependencies turns the locked packages named by a package in dir into
+// build.zig.zon entries. Only direct dependencies are listed: Zig fetches
+// the dependencies of a package from that package's own build.zig.zon, so
+// the versions yuki resolved for them are not pinned this way.
+func zonDependencies(dir string, names []string, lockFile *manifest.LockFile, resolved map[string]resolver.ResolvedDependency) ([]vendor.ZonDependency, error) {
+	var deps []vendor.ZonDependency
+
+	for _, name := range names {
+		pkg, locked := lockFile.Find(name)
+		dep, exists := resolved[name]
+		if !locked || !exists {
+			return nil, fmt.Errorf("package '%s' was not resolved", name)
+		}
+
+		if dep.Local {
+			rel, err := filepath.Rel(dir, dep.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate '%s': %w", pkg.Name, err)
+			}
+			deps = append(deps, vendor.ZonDependency{Name: pkg.Name, Path: filepath.ToSlash(rel)})
 			continue
 		}
 
-		result, err := fetcher.FetchDependency(dep.Name, manifestDep)
+		src, err := source.Parse(pkg.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source of '%s': %w", pkg.Name, err)
+		}
+		fetchable, ok := src.(source.ZigFetchable)
+		if !ok {
+			return nil, fmt.Errorf("'%s' comes from %s, which Zig cannot fetch", pkg.Name, pkg.Source)
+		}
+		if _, isGit := src.(source.Branches); isGit && pkg.Commit == "" {
+			return nil, fmt.Errorf("'%s' has no commit in yuki.lock", pkg.Name)
+		}
+
+		hash, err := zonHash(pkg, dep.Path)
 		if err != nil {
-			return fmt.Errorf("failed to fetch dependency '%s': %w", dep.Name, err)
+			return nil, err
 		}
 
-		if err := vendorer.VendorDependency(dep.Name, result.Path, cwd); err != nil {
-			return fmt.Errorf("failed to vendor dependency '%s': %w", dep.Name, err)
+		deps = append(deps, vendor.ZonDependency{Name: pkg.Name, URL: fetchable.ZigURL(pkg.Commit), Hash: hash})
+	}
+
+	return deps, nil
+}
+
+// zonHash returns the package hash of a locked package, which is calculated
+// from its contents when yuki.lock records another checksum.
+func zonHash(pkg manifest.LockedPackage, path string) (string, error) {
+	if algorithm, checksum, err := integrity.Parse(pkg.Integrity); err == nil && algorithm == integrity.ZigHash {
+		return checksum, nil
+	}
+
+	hash, err := integrity.CalculateZigHash(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate package hash of '%s': %w", pkg.Name, err)
+	}
+	return hash, nil
+}
+
+// updateChecksums lets the packages given with --update-checksum take new
+// contents for their locked version.
+func updateChecksums(res *resolver.Resolver, lockFile *manifest.LockFile, names []string) error {
+	for _, name := range names {
+		if _, locked := lockFile.Find(name); !locked {
+			return fmt.Errorf("package '%s' is not in yuki.lock", name)
 		}
+		res.UpdateChecksum(name)
+	}
+	return nil
+}
 
-		lockFile.Package = append(lockFile.Package, manifest.LockedPackage{
-			Name:     dep.Name,
-			Version:  result.Version,
-			Source:   manifestDep.Git,
-			Checksum: result.Checksum,
-		})
+// updateMemberFiles regenerates yuki.zig and build.zig of one package from
+// the part of lockFile it depends on.
+func updateMemberFiles(vendorer *vendor.Vendorer, project *workspace.Project, member workspace.Member, lockFile *manifest.LockFile, features *featureFlags, skipBuildUpdate bool) error {
+	dir := member.Dir
 
-		logger.Success("Installed '%s@%s'", dep.Name, result.Version)
+	enabled, err := features.resolve(member.Manifest)
+	if err != nil {
+		return err
 	}
-	
-	if err := lockFile.Save(cwd); err != nil {
-		return fmt.Errorf("failed to save lock file: %w", err)
+
+	if dir != project.Root {
+		if err := vendorer.LinkModules(dir, project.Root); err != nil {
+			return fmt.Errorf("failed to link yuki_modules of '%s': %w", member.Name, err)
+		}
 	}
 
-	if err := vendorer.GenerateYukiZig(cwd, lockFile, m); err != nil {
+	memberLock := lockFile.Subset(member.Manifest.DependencyNames())
+
+	if err := vendorer.GenerateYukiZig(dir, memberLock, member.Manifest, enabled); err != nil {
 		return fmt.Errorf("failed to generate yuki.zig: %w", err)
 	}
 
-	if !skipBuildUpdate {
-		logger.Info("Updating build.zig with dependencies...")
-		if err := vendorer.UpdateBuildZig(cwd, lockFile, m); err != nil {
-			logger.Warn("Failed to update build.zig: %v", err)
-			logger.Info("You may need to manually add dependencies to your build.zig")
-		} else {
-			logger.Success("Successfully updated build.zig")
-		}
+	if skipBuildUpdate {
+		return nil
 	}
-	
+
+	logger.Info("Updating build.zig with dependencies...")
+	if err := vendorer.UpdateBuildZig(dir, memberLock, member.Manifest, enabled); err != nil {
+		logger.Warn("Failed to update build.zig: %v", err)
+		logger.Info("You may need to manually add dependencies to your build.zig")
+		return nil
+	}
+	logger.Success("Successfully updated build.zig")
+
 	logger.Info("Formatting build.zig...")
-    cmdFmt := exec.Command("zig", "fmt", "build.zig")
+    cmdFmt := exec.Command("zig", "fmt", filepath.Join(dir, vendor.BuildZigFile))
     cmdFmt.Stdout = os.Stdout
     cmdFmt.Stderr = os.Stderr
     if err := cmdFmt.Run(); err != nil {
@@ -126,10 +309,78 @@ func runInstall(cmd *cobra.Command, args []string) error {
        logger.Success("Finished formatting!")
     }
 
-	logger.Success("Successfully installed %d dependencies", len(resolution.Dependencies))
-	if !skipBuildUpdate {
-		logger.Info("Dependencies have been automatically added to build.zig")
+	return nil
+}
+
+func declaresFeatures(members []workspace.Member) bool {
+	for _, member := range members {
+		if len(member.Manifest.FeatureNames()) > 0 {
+			return true
+		}
 	}
-	
+	return false
+}
+
+// vendorDependencies copies resolved packages into yuki_modules using up to
+// jobs workers and removes the packages that are no longer resolved. Results
+// are reported in resolution order once every copy has finished, so the
+// output does not depend on which copy completes first.
+func vendorDependencies(vendorer *vendor.Vendorer, projectRoot string, deps []resolver.ResolvedDependency, jobs int) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	errs := make([]error, len(deps))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	for i, dep := range deps {
+		if dep.Vendored {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, dep resolver.ResolvedDependency) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if dep.Local {
+				errs[i] = vendorer.LinkDependency(dep.Name, dep.Path, projectRoot)
+			} else {
+				errs[i] = vendorer.VendorDependency(dep.Name, dep.Path, projectRoot)
+			}
+		}(i, dep)
+	}
+	wg.Wait()
+
+	for i, dep := range deps {
+		if dep.Vendored {
+			logger.Debug("'%s@%s' is already vendored", dep.Name, dep.Version)
+			if !dep.Local {
+				if err := vendorer.EnsureIndex(projectRoot, dep.Name); err != nil {
+					logger.Warn("Failed to index '%s': %v", dep.Name, err)
+				}
+			}
+			continue
+		}
+		if errs[i] != nil {
+			return fmt.Errorf("failed to vendor dependency '%s': %w", dep.Name, errs[i])
+		}
+		logger.Success("Installed '%s@%s'", dep.Name, dep.Version)
+	}
+
+	names := make([]string, len(deps))
+	for i, dep := range deps {
+		names[i] = dep.Name
+	}
+	removed, err := vendorer.PruneDependencies(projectRoot, names)
+	if err != nil {
+		return fmt.Errorf("failed to remove unused packages: %w", err)
+	}
+	for _, name := range removed {
+		logger.Info("Removed '%s', which is no longer a dependency", name)
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -2,91 +2,247 @@ package cli
 
 import (
         "fmt"
+        "strings"
 
         "github.com/spf13/cobra"
         "yuki_zpm.org/logger"
         "yuki_zpm.org/manifest"
+        "yuki_zpm.org/source"
+        "yuki_zpm.org/workspace"
 )
 
+type listOptions struct {
+        tree  bool
+        depth int
+        kinds []string
+}
+
 func ListCmd() *cobra.Command {
-        var tree bool
+        var opts listOptions
 
         cmd := &cobra.Command{
                 Use:   "list",
                 Short: "List dependencies",
                 Long:  "List all dependencies in the project",
                 RunE: func(cmd *cobra.Command, args []string) error {
-                        return runList(tree)
+                        return runList(&opts)
                 },
         }
 
-        cmd.Flags().BoolVarP(&tree, "tree", "t", false, "Show dependencies in tree format")
+        cmd.Flags().BoolVarP(&opts.tree, "tree", "t", false, "Show dependencies in tree format")
+        cmd.Flags().IntVar(&opts.depth, "depth", -1, "Maximum depth of the tree (-1 for no limit)")
+        cmd.Flags().StringSliceVar(&opts.kinds, "kind", nil, "Only show these kinds of dependencies: normal, dev or build")
 
         return cmd
 }
 
-func runList(tree bool) error {
-        cwd := "."
-        
-        m, err := manifest.Load(cwd)
+func runList(opts *listOptions) error {
+        for _, kind := range opts.kinds {
+                if kind != manifest.KindNormal && kind != manifest.KindDev && kind != manifest.KindBuild {
+                        return fmt.Errorf("unknown dependency kind '%s' (expected normal, dev or build)", kind)
+                }
+        }
+
+        project, err := loadProject()
         if err != nil {
-                logger.Error("No yuki.toml found. Run 'yuki init' first.")
-                return fmt.Errorf("manifest not found: %w", err)
+                return err
         }
-        
-        lockFile, err := manifest.LoadLockFile(cwd)
+
+        lockFile, err := manifest.LoadLockFile(project.Root)
         if err != nil {
                 logger.Warn("Lock file not found, showing manifest dependencies only")
+                lockFile = &manifest.LockFile{}
         }
 
-        installedVersions := make(map[string]string)
-        if lockFile != nil {
-                for _, pkg := range lockFile.Package {
-                        installedVersions[pkg.Name] = pkg.Version
+        graph := newDepGraph(project.Root, lockFile)
+
+        for i, member := range project.Selected {
+                if opts.tree {
+                        if i > 0 {
+                                fmt.Println()
+                        }
+                        printTree(graph, member, opts)
+                } else {
+                        listPackage(graph, member.Manifest, opts)
                 }
         }
 
-        logger.Info("Dependencies for %s:", m.Package.Name)
+        return nil
+}
 
-        if len(m.Dependencies) > 0 {
-                fmt.Println("\nDependencies:")
-                listDependencies(m.Dependencies, installedVersions, tree, "")
+// showKind reports whether edges of kind pass the --kind filter.
+func (opts *listOptions) showKind(kind string) bool {
+        if len(opts.kinds) == 0 {
+                return true
         }
-
-        if len(m.DevDeps) > 0 {
-                fmt.Println("\nDev Dependencies:")
-                listDependencies(m.DevDeps, installedVersions, tree, "")
+        category := edgeCategory(kind)
+        for _, k := range opts.kinds {
+                if k == category {
+                        return true
+                }
         }
+        return false
+}
 
-        if len(m.BuildDeps) > 0 {
-                fmt.Println("\nBuild Dependencies:")
-                listDependencies(m.BuildDeps, installedVersions, tree, "")
+// edgeCategory returns normal, dev or build for an edge kind.
+func edgeCategory(kind string) string {
+        switch {
+        case kind == manifest.KindDev || strings.HasPrefix(kind, manifest.KindDev+" "):
+                return manifest.KindDev
+        case kind == manifest.KindBuild || strings.HasPrefix(kind, manifest.KindBuild+" "):
+                return manifest.KindBuild
+        default:
+                return manifest.KindNormal
         }
+}
 
-        if len(m.Dependencies) == 0 && len(m.DevDeps) == 0 && len(m.BuildDeps) == 0 {
+func listPackage(graph *depGraph, m *manifest.Manifest, opts *listOptions) {
+        logger.Info("Dependencies for %s:", m.Package.Name)
+
+        if len(m.GetAllDependencies()) == 0 {
                 logger.Info("No dependencies found")
+                return
         }
 
-        return nil
+        sections := []struct {
+                title string
+                kind  string
+                deps  map[string]manifest.Dependency
+        }{
+                {"Dependencies", manifest.KindNormal, m.Dependencies},
+                {"Dev Dependencies", manifest.KindDev, m.DevDeps},
+                {"Build Dependencies", manifest.KindBuild, m.BuildDeps},
+        }
+
+        for _, section := range sections {
+                if len(section.deps) > 0 && opts.showKind(section.kind) {
+                        fmt.Printf("\n%s:\n", section.title)
+                        listDependencies(graph, section.deps)
+                }
+        }
+
+        for _, spec := range m.TargetNames() {
+                target := m.Target[spec]
+                if len(target.Dependencies) > 0 && opts.showKind(manifest.KindNormal) {
+                        fmt.Printf("\nDependencies (%s):\n", spec)
+                        listDependencies(graph, target.Dependencies)
+                }
+                if len(target.DevDeps) > 0 && opts.showKind(manifest.KindDev) {
+                        fmt.Printf("\nDev Dependencies (%s):\n", spec)
+                        listDependencies(graph, target.DevDeps)
+                }
+                if len(target.BuildDeps) > 0 && opts.showKind(manifest.KindBuild) {
+                        fmt.Printf("\nBuild Dependencies (%s):\n", spec)
+                        listDependencies(graph, target.BuildDeps)
+                }
+        }
 }
 
-func listDependencies(deps map[string]manifest.Dependency, installedVersions map[string]string, tree bool, prefix string) {
-        for name, dep := range deps {
-                installedVersion := installedVersions[name]
-                
+func listDependencies(graph *depGraph, deps map[string]manifest.Dependency) {
+        for _, name := range manifest.SortedDependencyNames(deps) {
+                dep := deps[name]
+                pkg, isInstalled := graph.locked(name)
+
                 var versionInfo string
-                if installedVersion != "" {
-                        versionInfo = fmt.Sprintf(" (installed: %s)", installedVersion)
+                if isInstalled {
+                        versionInfo = fmt.Sprintf(" (installed: %s)", pkg.Version)
                 } else {
                         versionInfo = " (not installed)"
                 }
-                
-                if tree {
-                        fmt.Printf("%s├── %s@%s%s\n", prefix, name, dep.Version, versionInfo)
-                        fmt.Printf("%s│   └── %s\n", prefix, dep.Git)
-                } else {
-                        fmt.Printf("  %s@%s%s\n", name, dep.Version, versionInfo)
-                        fmt.Printf("    Source: %s\n", dep.Git)
+
+                sourceInfo := source.Describe(dep)
+                if pkg.Patched != "" {
+                        versionInfo += " [patched]"
+                        sourceInfo = fmt.Sprintf("%s (patched from %s)", pkg.Source, pkg.Patched)
                 }
+
+                fmt.Printf("  %s@%s%s\n", name, dep.Version, versionInfo)
+                fmt.Printf("    Source: %s\n", sourceInfo)
+        }
+}
+
+// printTree prints the dependency tree of a member from the lock graph, in
+// the style of cargo tree. A package whose dependencies were already shown
+// earlier in the same tree is marked with (*) instead of being expanded
+// again.
+func printTree(graph *depGraph, member workspace.Member, opts *listOptions) {
+        m := member.Manifest
+        fmt.Printf("%s %s\n", m.Package.Name, m.Package.Version)
+
+        if opts.depth == 0 {
+                return
+        }
+
+        expanded := make(map[string]bool)
+        sections := []struct {
+                heading  string
+                category string
+        }{
+                {"", manifest.KindNormal},
+                {"[dev-dependencies]", manifest.KindDev},
+                {"[build-dependencies]", manifest.KindBuild},
+        }
+
+        for _, section := range sections {
+                var edges []depEdge
+                for _, edge := range graph.memberEdges(m) {
+                        if edgeCategory(edge.kind) == section.category && opts.showKind(edge.kind) {
+                                edges = append(edges, edge)
+                        }
+                }
+                if len(edges) == 0 {
+                        continue
+                }
+                if section.heading != "" {
+                        fmt.Println(section.heading)
+                }
+                printTreeEdges(graph, edges, "", 1, opts.depth, expanded)
+        }
+}
+
+func printTreeEdges(graph *depGraph, edges []depEdge, prefix string, depth, maxDepth int, expanded map[string]bool) {
+        for i, edge := range edges {
+                branch, indent := "├── ", "│   "
+                if i == len(edges)-1 {
+                        branch, indent = "└── ", "    "
+                }
+
+                children := graph.packageEdges(edge.name)
+                repeated := expanded[edge.name] && len(children) > 0
+
+                fmt.Printf("%s%s%s\n", prefix, branch, treeLabel(graph, edge, repeated))
+
+                if repeated || (maxDepth >= 0 && depth >= maxDepth) {
+                        continue
+                }
+                expanded[edge.name] = true
+                printTreeEdges(graph, children, prefix+indent, depth+1, maxDepth, expanded)
+        }
+}
+
+// treeLabel describes a node of the tree: the locked version and commit,
+// the requirement of the dependent and any markers.
+func treeLabel(graph *depGraph, edge depEdge, repeated bool) string {
+        var sb strings.Builder
+        sb.WriteString(edge.name)
+
+        pkg, locked := graph.locked(edge.name)
+        if locked {
+                sb.WriteString(" " + shortRef(pkg.Version))
+        }
+        sb.WriteString(" (" + describeEdge(edge) + ")")
+
+        switch {
+        case !locked:
+                sb.WriteString(" (not installed)")
+        case pkg.Commit != "" && pkg.Commit != pkg.Version:
+                sb.WriteString(" [" + shortRef(pkg.Commit) + "]")
+        }
+        if pkg.Patched != "" {
+                sb.WriteString(" [patched]")
+        }
+        if repeated {
+                sb.WriteString(" (*)")
         }
+        return sb.String()
 }
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/resolver"
+)
+
+func LockCmd() *cobra.Command {
+	var check bool
+	var jobs int
+	var updateChecksum []string
+
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Update yuki.lock without installing",
+		Long:  "Resolve the dependencies in yuki.toml and write yuki.lock, without vendoring packages or touching build files. Versions already in yuki.lock are kept when they still satisfy yuki.toml.",
+		// --check reports an outdated lock file as an error, which is not
+		// a usage error.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLock(check, jobs, updateChecksum)
+		},
+	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "Fail if yuki.lock is out of date instead of writing it")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Number of packages to resolve in parallel")
+	cmd.Flags().StringSliceVar(&updateChecksum, "update-checksum", nil, "Accept new contents for these packages when their tag moved upstream")
+
+	return cmd
+}
+
+func runLock(check bool, jobs int, updateChecksum []string) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+	root := project.Root
+
+	existingLock, err := manifest.LoadLockFile(root)
+	if err != nil {
+		return fmt.Errorf("failed to load lock file: %w", err)
+	}
+
+	res := resolver.New()
+	res.SetProjectRoot(root)
+	res.SetPatches(project.Patch)
+	res.SetZigVersion(localZigVersion(project))
+	res.UseLockFile(existingLock)
+	res.UseVendored(root)
+	res.SetJobs(jobs)
+	if err := updateChecksums(res, existingLock, updateChecksum); err != nil {
+		return err
+	}
+
+	resolution, err := res.ResolveWorkspace(project.Members)
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies: %w", err)
+	}
+
+	lockFile := resolution.LockFile()
+	lockFile.Metadata.Fingerprint = project.Fingerprint()
+	changes := manifest.DiffLockFiles(existingLock, lockFile)
+
+	if check {
+		if len(changes) > 0 {
+			logger.Error("yuki.lock is out of date:")
+			for _, change := range changes {
+				logger.Error("  %s", change)
+			}
+			return fmt.Errorf("yuki.lock needs to be updated")
+		}
+		logger.Success("yuki.lock is up to date")
+		return nil
+	}
+
+	// The lock file is written even without changes, so that one from an
+	// older version of yuki is upgraded.
+	if err := lockFile.Save(root); err != nil {
+		return fmt.Errorf("failed to save lock file: %w", err)
+	}
+
+	if len(changes) == 0 {
+		logger.Success("yuki.lock is up to date")
+		return nil
+	}
+
+	for _, change := range changes {
+		logger.Info("  %s", change)
+	}
+	logger.Success("Updated yuki.lock with %d dependencies", len(resolution.Dependencies))
+	logger.Info("Run 'yuki install' to vendor them")
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"yuki_zpm.org/logger"
@@ -10,48 +13,147 @@ import (
 )
 
 func OutdatedCmd() *cobra.Command {
-	return &cobra.Command{
+	var exitCode bool
+	var format string
+
+	cmd := &cobra.Command{
 		Use:   "outdated",
 		Short: "Show outdated dependencies",
-		Long:  "List dependencies that have newer versions available",
-		RunE:  runOutdated,
+		Long:  "List dependencies that have newer versions available. Wanted is the newest version the requirements in yuki.toml allow, Latest the newest version published. Branch and rev dependencies compare commits.",
+		// --exit-code reports outdated dependencies as an error, which is
+		// not a usage error.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOutdated(exitCode, format)
+		},
 	}
+
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with status 1 when a dependency is outdated")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
 }
 
-func runOutdated(cmd *cobra.Command, args []string) error {
-	cwd := "."
-	
-	
-	lockFile, err := manifest.LoadLockFile(cwd)
+func runOutdated(exitCode bool, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format '%s' (expected text or json)", format)
+	}
+
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := manifest.LoadLockFile(project.Root)
 	if err != nil {
 		return fmt.Errorf("failed to load lock file: %w", err)
 	}
 
-	if len(lockFile.Package) == 0 {
+	// Only the packages the selected members depend on are reported.
+	var names []string
+	for _, member := range project.Selected {
+		names = append(names, member.Manifest.DependencyNames()...)
+	}
+	lockFile = lockFile.Subset(names)
+
+	if len(lockFile.Package) == 0 && format == "text" {
 		logger.Info("No dependencies installed")
 		return nil
 	}
 
-	logger.Info("Checking for outdated dependencies...")
-
-	resolver := resolver.New()
-	updates, err := resolver.CheckForUpdates(lockFile)
-	if err != nil {
-		return fmt.Errorf("failed to check for updates: %w", err)
+	if format == "text" {
+		logger.Info("Checking for outdated dependencies...")
 	}
 
-	if len(updates) == 0 {
-		logger.Success("All dependencies are up to date!")
-		return nil
+	res := resolver.New()
+	res.SetProjectRoot(project.Root)
+	res.SetPatches(project.Patch)
+	res.UseVendored(project.Root)
+	res.SetJobs(runtime.NumCPU())
+
+	infos := res.CheckOutdated(project.Selected, lockFile)
+
+	var outdated []resolver.OutdatedInfo
+	for _, info := range infos {
+		if info.Outdated {
+			outdated = append(outdated, info)
+		}
 	}
 
-	logger.Info("Outdated dependencies:")
-	for _, update := range updates {
-		logger.Warn("  %-20s %s → %s", update.Name, update.CurrentVersion, update.LatestVersion)
+	if format == "json" {
+		if infos == nil {
+			infos = []resolver.OutdatedInfo{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(infos); err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+	} else {
+		for _, info := range infos {
+			if info.Error != "" {
+				logger.Warn("Could not check '%s': %s", info.Name, info.Error)
+			}
+		}
+
+		if len(outdated) == 0 {
+			logger.Success("All dependencies are up to date!")
+			return nil
+		}
+
+		printOutdated(outdated)
+
+		logger.Info("Run 'yuki update' to update all dependencies within their requirements")
+		logger.Info("Run 'yuki update <package>' to update specific packages")
 	}
 
-	logger.Info("\nRun 'yuki update' to update all dependencies")
-	logger.Info("Run 'yuki add <package>@<version>' to update specific packages")
+	if exitCode && len(outdated) > 0 {
+		return fmt.Errorf("found %d outdated dependencies", len(outdated))
+	}
 
 	return nil
 }
+
+func printOutdated(infos []resolver.OutdatedInfo) {
+	rows := [][]string{{"Package", "Current", "Wanted", "Latest", "Kind"}}
+	for _, info := range infos {
+		name := info.Name
+		if !info.Direct {
+			name += " (transitive)"
+		}
+		kind := info.Kind
+		if info.Ref != "" {
+			kind += " " + shortRef(info.Ref)
+		}
+		rows = append(rows, []string{name, shortRef(info.Current), shortRef(info.Wanted), shortRef(info.Latest), kind})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	fmt.Println()
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(row)-1 {
+				fmt.Println(cell)
+			} else {
+				fmt.Printf("%-*s  ", widths[i], cell)
+			}
+		}
+	}
+	fmt.Println()
+}
+
+// shortRef shortens full commit hashes for display.
+func shortRef(ref string) string {
+	if len(ref) == 40 {
+		return ref[:8]
+	}
+	return ref
+}
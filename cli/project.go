@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/utils"
+	"yuki_zpm.org/workspace"
+)
+
+var selectedPackage string
+
+// SetPackage selects the workspace member commands work on, as given with
+// the global -p flag.
+func SetPackage(name string) {
+	selectedPackage = name
+}
+
+func loadProject() (*workspace.Project, error) {
+	project, err := workspace.Load(".", selectedPackage)
+	if errors.Is(err, workspace.ErrNoManifest) {
+		logger.Error("No yuki.toml found. Run 'yuki init' first.")
+		return nil, fmt.Errorf("manifest not found: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+	return project, nil
+}
+
+// localZigVersion returns the installed Zig version, which dependencies are
+// checked against, and warns about selected packages that need another one.
+func localZigVersion(project *workspace.Project) string {
+	zigVersion := utils.DetectZigVersion()
+	if zigVersion == "" {
+		logger.Debug("Zig was not found, so zig_version is not checked")
+		return ""
+	}
+
+	for _, member := range project.Selected {
+		required := member.Manifest.Package.ZigVersion
+		if required == "" {
+			continue
+		}
+		if ok, err := utils.ZigVersionSatisfies(required, zigVersion); err == nil && !ok {
+			logger.Warn("'%s' needs %s, but zig %s is installed", member.Name, utils.DescribeZigRequirement(required), zigVersion)
+		}
+	}
+
+	return zigVersion
+}
+
+// staleLock explains why yuki.lock does not match the dependencies in
+// yuki.toml, or returns "" if it does. Lock files written without a
+// fingerprint can only be checked for missing packages.
+func staleLock(project *workspace.Project) (string, error) {
+	lockFile, err := manifest.LoadLockFile(project.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to load lock file: %w", err)
+	}
+
+	if lockFile.Metadata.Fingerprint != "" {
+		if lockFile.Metadata.Fingerprint != project.Fingerprint() {
+			return "yuki.toml was changed after yuki.lock was generated", nil
+		}
+		return "", nil
+	}
+
+	for _, name := range project.Dependencies() {
+		if _, locked := lockFile.Find(name); !locked {
+			return fmt.Sprintf("'%s' is not in yuki.lock", name), nil
+		}
+	}
+	return "", nil
+}
+
+// checkLock warns when yuki.lock is out of date, or fails if locked is set.
+func checkLock(project *workspace.Project, locked bool) error {
+	reason, err := staleLock(project)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return nil
+	}
+
+	if locked {
+		logger.Error("yuki.lock is out of date: %s", reason)
+		logger.Info("Run 'yuki lock' or 'yuki install' to update it")
+		return fmt.Errorf("yuki.lock needs to be updated but --locked was passed")
+	}
+	logger.Warn("yuki.lock is out of date: %s", reason)
+	logger.Warn("Run 'yuki lock' or 'yuki install' to update it")
+	return nil
+}
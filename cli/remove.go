@@ -27,14 +27,18 @@ func RemoveCmd() *cobra.Command {
 }
 
 func runRemove(packageName string, skipBuildUpdate bool) error {
-	cwd := "."
-	
-	m, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
 	}
 
+	member, err := project.Single()
+	if err != nil {
+		return err
+	}
+	m := member.Manifest
+	fingerprint := project.Fingerprint()
+
 	logger.Info("Removing dependency '%s'", packageName)
 
 	removed := false
@@ -57,47 +61,80 @@ func runRemove(packageName string, skipBuildUpdate bool) error {
 		logger.Info("Removed '%s' from build-dependencies", packageName)
 	}
 
+	for _, spec := range m.TargetNames() {
+		for _, deps := range []map[string]manifest.Dependency{m.Target[spec].Dependencies, m.Target[spec].DevDeps, m.Target[spec].BuildDeps} {
+			if _, exists := deps[packageName]; exists {
+				delete(deps, packageName)
+				removed = true
+				logger.Info("Removed '%s' from %s dependencies", packageName, spec)
+			}
+		}
+	}
+
 	if !removed {
 		logger.Error("Dependency '%s' not found", packageName)
 		return fmt.Errorf("dependency not found")
 	}
 
-	if err := m.Save(cwd); err != nil {
+	for feature, entries := range m.Features {
+		var kept []string
+		for _, entry := range entries {
+			if entry == "dep:"+packageName || (entry == packageName && m.Features[entry] == nil) {
+				logger.Info("Removed '%s' from feature '%s'", packageName, feature)
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		if kept == nil {
+			kept = []string{}
+		}
+		m.Features[feature] = kept
+	}
+
+	if err := m.Save(member.Dir); err != nil {
 		return fmt.Errorf("failed to save manifest: %w", err)
 	}
 
-	lockFile, err := manifest.LoadLockFile(cwd)
+	lockFile, err := manifest.LoadLockFile(project.Root)
 	if err != nil {
 		return fmt.Errorf("failed to load lock file: %w", err)
 	}
 
-	var updatedPackages []manifest.LockedPackage
-	for _, pkg := range lockFile.Package {
-		if pkg.Name != packageName {
-			updatedPackages = append(updatedPackages, pkg)
-		}
+	// Other workspace members may still depend on the package.
+	orphaned := lockFile.PruneUnreachable(project.Dependencies())
+	// A lock file that matched yuki.toml still does after pruning.
+	if lockFile.Metadata.Fingerprint == fingerprint {
+		lockFile.Metadata.Fingerprint = project.Fingerprint()
 	}
-	lockFile.Package = updatedPackages
 
-	if err := lockFile.Save(cwd); err != nil {
+	if err := lockFile.Save(project.Root); err != nil {
 		return fmt.Errorf("failed to save lock file: %w", err)
 	}
 
 	vendorer := vendor.New()
 	
-	if err := vendorer.RemovePackageFiles(cwd, packageName); err != nil {
-		logger.Warn("Failed to remove package files: %v", err)
-	} else {
-		logger.Info("Removed package files for '%s'", packageName)
+	for _, name := range orphaned {
+		if err := vendorer.RemovePackageFiles(project.Root, name); err != nil {
+			logger.Warn("Failed to remove package files: %v", err)
+		} else {
+			logger.Info("Removed package files for '%s'", name)
+		}
 	}
 
-	if err := vendorer.GenerateYukiZig(cwd, lockFile, m); err != nil {
+	memberLock := lockFile.Subset(m.DependencyNames())
+
+	features, err := m.ResolveFeatures(nil, true)
+	if err != nil {
+		logger.Warn("Failed to resolve the default features, leaving optional dependencies out: %v", err)
+		features = &manifest.FeatureSet{}
+	}
+	if err := vendorer.GenerateYukiZig(member.Dir, memberLock, m, features); err != nil {
 		logger.Warn("Failed to regenerate yuki.zig: %v", err)
 	}
 	
 	if !skipBuildUpdate {
 		logger.Info("Updating build.zig...")
-		if err := vendorer.UpdateBuildZig(cwd, lockFile, m); err != nil {
+		if err := vendorer.UpdateBuildZig(member.Dir, memberLock, m, features); err != nil {
 			logger.Warn("Failed to update build.zig: %v", err)
 			logger.Info("You may need to manually remove the dependency from your build.zig")
 		} else {
@@ -114,4 +151,4 @@ func runRemove(packageName string, skipBuildUpdate bool) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
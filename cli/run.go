@@ -6,17 +6,43 @@ import (
 )
 
 func RunCmd() *cobra.Command {
-	return &cobra.Command{
+	var locked bool
+	var features featureFlags
+
+	cmd := &cobra.Command{
 		Use:   "run",
 		Short: "Build and run the project",
 		Long:  "Compile and execute the project with all dependencies",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRun(args)
+			return runRun(args, locked, &features)
 		},
 	}
+
+	cmd.Flags().BoolVar(&locked, "locked", false, "Fail if yuki.lock is out of date with yuki.toml")
+	features.register(cmd)
+
+	return cmd
 }
 
-func runRun(args []string) error {
+func runRun(args []string, locked bool, features *featureFlags) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	member, err := project.Single()
+	if err != nil {
+		return err
+	}
+
+	if err := checkLock(project, locked); err != nil {
+		return err
+	}
+
+	if err := applyFeatures(project, features); err != nil {
+		return err
+	}
+
 	builder := build.New()
-	return builder.Run(".", args)
+	return builder.Run(member.Dir, args)
 }
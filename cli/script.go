@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/workspace"
+)
+
+func ScriptCmd() *cobra.Command {
+	var list bool
+
+	cmd := &cobra.Command{
+		Use:   "script <name> [-- args]",
+		Short: "Run a script from yuki.toml",
+		Long:  "Run a script from the [scripts] table of yuki.toml. The scripts pre<name> and post<name> run before and after it when they exist.",
+		// A failing script is not a usage error.
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list {
+				return runScriptList()
+			}
+			return runScript(args[0], args[1:])
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", false, "List the scripts of the project")
+
+	return cmd
+}
+
+// ScriptAlias rewrites "yuki <name> ..." to "yuki script <name> ..." when
+// name is a script of the project and not a command. It returns nil when the
+// arguments should be used as they are.
+func ScriptAlias(root *cobra.Command, args []string) []string {
+	pkg := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return nil
+		}
+		if strings.HasPrefix(arg, "-") {
+			switch {
+			case arg == "-p" || arg == "--package":
+				if i+1 < len(args) {
+					pkg = args[i+1]
+				}
+				i++
+			case strings.HasPrefix(arg, "--package="):
+				pkg = strings.TrimPrefix(arg, "--package=")
+			}
+			continue
+		}
+
+		// help and completion are only added to root when it executes.
+		if arg == "help" || arg == "completion" || strings.HasPrefix(arg, "__complete") {
+			return nil
+		}
+		if cmd, _, err := root.Find([]string{arg}); err == nil && cmd != root {
+			return nil
+		}
+		if !hasScript(arg, pkg) {
+			return nil
+		}
+
+		rewritten := append([]string{}, args[:i]...)
+		rewritten = append(rewritten, "script")
+		return append(rewritten, args[i:]...)
+	}
+	return nil
+}
+
+func hasScript(name, pkg string) bool {
+	project, err := workspace.Load(".", pkg)
+	if err != nil {
+		return false
+	}
+	for _, member := range project.Selected {
+		if _, ok := member.Manifest.Scripts[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func runScriptList() error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	for i, member := range project.Selected {
+		if project.Workspace {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", member.Name)
+		}
+
+		names := scriptNames(member.Manifest.Scripts)
+		if len(names) == 0 {
+			logger.Info("No scripts defined")
+			continue
+		}
+
+		width := 0
+		for _, name := range names {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		for _, name := range names {
+			fmt.Printf("  %-*s  %s\n", width, name, member.Manifest.Scripts[name])
+		}
+	}
+
+	return nil
+}
+
+// runScript runs the script in every selected package that defines it, so
+// that a script can be run across a whole workspace from its root.
+func runScript(name string, args []string) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	ran := false
+	for _, member := range project.Selected {
+		if _, ok := member.Manifest.Scripts[name]; !ok {
+			continue
+		}
+		ran = true
+
+		env, err := scriptEnv(project, member, name)
+		if err != nil {
+			return err
+		}
+
+		if err := runScriptHook(member, "pre"+name, env); err != nil {
+			return err
+		}
+		if err := runScriptCommand(member, name, args, env); err != nil {
+			return err
+		}
+		if err := runScriptHook(member, "post"+name, env); err != nil {
+			return err
+		}
+	}
+
+	if !ran {
+		if len(project.Selected) == 1 {
+			return fmt.Errorf("no script named '%s' in %s (run 'yuki script --list' to see the scripts)", name, project.Selected[0].Name)
+		}
+		return fmt.Errorf("no script named '%s' in any selected package", name)
+	}
+
+	return nil
+}
+
+// runScriptHook runs a pre or post script if the package defines it. Hooks
+// do not get the arguments given to the script.
+func runScriptHook(member workspace.Member, hook string, env []string) error {
+	if _, ok := member.Manifest.Scripts[hook]; !ok {
+		return nil
+	}
+	return runScriptCommand(member, hook, nil, env)
+}
+
+func runScriptCommand(member workspace.Member, name string, args []string, env []string) error {
+	script := member.Manifest.Scripts[name]
+	logger.Info("> %s: %s", name, script)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", append([]string{"/C", script}, args...)...)
+	} else if len(args) > 0 {
+		// Extra arguments are passed as positional parameters so the shell
+		// does not split or expand them again.
+		cmd = exec.Command("sh", append([]string{"-c", script + ` "$@"`, "sh"}, args...)...)
+	} else {
+		cmd = exec.Command("sh", "-c", script)
+	}
+	cmd.Dir = member.Dir
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("script '%s' failed: %w", name, err)
+	}
+	return nil
+}
+
+// scriptEnv returns the environment of a script: the caller's environment
+// and variables describing the package it belongs to.
+func scriptEnv(project *workspace.Project, member workspace.Member, name string) ([]string, error) {
+	packageRoot, err := filepath.Abs(member.Dir)
+	if err != nil {
+		return nil, err
+	}
+	workspaceRoot, err := filepath.Abs(project.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(os.Environ(),
+		"YUKI_PROJECT_ROOT="+packageRoot,
+		"YUKI_WORKSPACE_ROOT="+workspaceRoot,
+		"YUKI_MODULES="+filepath.Join(workspaceRoot, vendor.VendorDir),
+		"YUKI_PACKAGE_NAME="+member.Manifest.Package.Name,
+		"YUKI_PACKAGE_VERSION="+member.Manifest.Package.Version,
+		"YUKI_SCRIPT="+name,
+	), nil
+}
+
+func scriptNames(scripts map[string]string) []string {
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
-	"yuki_zpm.org/resolver"
 )
 
 func SyncCmd() *cobra.Command {
@@ -19,25 +18,22 @@ func SyncCmd() *cobra.Command {
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
-	cwd := "."
-	
 	logger.Info("Synchronizing dependencies...")
 
 	
-	m, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
 	}
 
 	
-	lockFile, err := manifest.LoadLockFile(cwd)
+	lockFile, err := manifest.LoadLockFile(project.Root)
 	if err != nil {
 		return fmt.Errorf("failed to load lock file: %w", err)
 	}
 
 	
-	manifestDeps := m.GetAllDependencies()
+	roots := project.Dependencies()
 	
 	
 	inconsistencies := []string{}
@@ -48,17 +44,21 @@ func runSync(cmd *cobra.Command, args []string) error {
 		lockPkgMap[pkg.Name] = true
 	}
 	
-	for name := range manifestDeps {
+	if lockFile.Metadata.Fingerprint != "" && lockFile.Metadata.Fingerprint != project.Fingerprint() {
+		inconsistencies = append(inconsistencies, "yuki.toml was changed after yuki.lock was generated")
+	}
+
+	for _, name := range roots {
 		if !lockPkgMap[name] {
 			inconsistencies = append(inconsistencies, fmt.Sprintf("'%s' is in manifest but not in lock file", name))
 		}
 	}
 	
 	
-	for _, pkg := range lockFile.Package {
-		if _, exists := manifestDeps[pkg.Name]; !exists {
-			inconsistencies = append(inconsistencies, fmt.Sprintf("'%s' is in lock file but not in manifest", pkg.Name))
-		}
+	reachable := *lockFile
+	reachable.Package = append([]manifest.LockedPackage{}, lockFile.Package...)
+	for _, name := range reachable.PruneUnreachable(roots) {
+		inconsistencies = append(inconsistencies, fmt.Sprintf("'%s' is in lock file but not required by the manifest", name))
 	}
 
 	if len(inconsistencies) > 0 {
@@ -71,10 +71,11 @@ func runSync(cmd *cobra.Command, args []string) error {
 	}
 
 	
-	resolver := resolver.New()
-	if err := resolver.ValidateDependencies(m); err != nil {
-		logger.Error("Dependency validation failed: %v", err)
-		return err
+	for _, member := range project.Members {
+		if err := validateMember(member); err != nil {
+			logger.Error("Dependency validation failed: %v", err)
+			return err
+		}
 	}
 
 	logger.Success("Dependencies are synchronized and valid")
@@ -6,15 +6,43 @@ import (
 )
 
 func TestCmd() *cobra.Command {
-	return &cobra.Command{
+	var locked bool
+	var features featureFlags
+
+	cmd := &cobra.Command{
 		Use:   "test",
 		Short: "Run project tests",
 		Long:  "Run all tests with dependencies",
-		RunE:  runTest,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTest(locked, &features)
+		},
 	}
+
+	cmd.Flags().BoolVar(&locked, "locked", false, "Fail if yuki.lock is out of date with yuki.toml")
+	features.register(cmd)
+
+	return cmd
 }
 
-func runTest(cmd *cobra.Command, args []string) error {
+func runTest(locked bool, features *featureFlags) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	if err := checkLock(project, locked); err != nil {
+		return err
+	}
+
+	if err := applyFeatures(project, features); err != nil {
+		return err
+	}
+
 	builder := build.New()
-	return builder.Test(".")
+	for _, member := range project.Selected {
+		if err := builder.Test(member.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -2,75 +2,233 @@ package cli
 
 import (
 	"fmt"
+	"runtime"
+	"sort"
 
 	"github.com/spf13/cobra"
+	"yuki_zpm.org/internal/vendor"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
 	"yuki_zpm.org/resolver"
 )
 
+type updateOptions struct {
+	dryRun          bool
+	precise         string
+	recursive       bool
+	skipBuildUpdate bool
+	updateChecksum  []string
+	features        featureFlags
+}
+
 func UpdateCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "update [package]",
+	var opts updateOptions
+
+	cmd := &cobra.Command{
+		Use:   "update [package...]",
 		Short: "Update dependencies",
-		Long:  "Update dependencies to their latest compatible versions",
-		RunE:  runUpdate,
+		Long:  "Update dependencies to the newest versions their requirements in yuki.toml allow and rewrite yuki.lock. Without arguments every dependency is updated.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUpdate(args, &opts)
+		},
 	}
+
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show what would be updated without changing anything")
+	cmd.Flags().StringVar(&opts.precise, "precise", "", "Update the given package to exactly this version")
+	cmd.Flags().BoolVar(&opts.recursive, "recursive", false, "Also update the dependencies of the given packages")
+	cmd.Flags().BoolVar(&opts.skipBuildUpdate, "skip-build-update", false, "Skip updating build.zig with dependencies")
+	cmd.Flags().StringSliceVar(&opts.updateChecksum, "update-checksum", nil, "Accept new contents for these packages when their tag moved upstream")
+	opts.features.register(cmd)
+
+	return cmd
 }
 
-func runUpdate(cmd *cobra.Command, args []string) error {
-	cwd := "."
+func runUpdate(packages []string, opts *updateOptions) error {
+	if opts.precise != "" && len(packages) != 1 {
+		return fmt.Errorf("--precise needs exactly one package")
+	}
 
-	
-	_, err := manifest.Load(cwd)
+	project, err := loadProject()
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return err
+	}
+	root := project.Root
+
+	for _, member := range project.Selected {
+		if _, err := opts.features.resolve(member.Manifest); err != nil {
+			return err
+		}
 	}
 
-	
-	lockFile, err := manifest.LoadLockFile(cwd)
+	lockFile, err := manifest.LoadLockFile(root)
 	if err != nil {
 		return fmt.Errorf("failed to load lock file: %w", err)
 	}
 
-	logger.Info("Checking for dependency updates...")
+	dependencies := make(map[string]bool)
+	for _, name := range project.Dependencies() {
+		dependencies[name] = true
+	}
+	for _, name := range packages {
+		if _, locked := lockFile.Find(name); !locked && !dependencies[name] {
+			return fmt.Errorf("package '%s' is not a dependency of this project", name)
+		}
+	}
+
+	// Packages that are not being updated keep their locked versions as
+	// long as the new versions of the others still accept them.
+	var keep *manifest.LockFile
+	if len(packages) > 0 {
+		unlocked := packages
+		if opts.recursive {
+			unlocked = nil
+			for _, pkg := range lockFile.Subset(packages).Package {
+				unlocked = append(unlocked, pkg.Name)
+			}
+			unlocked = append(unlocked, packages...)
+		}
+		keep = lockFile.Without(unlocked)
+	}
+
+	logger.Info("Updating dependencies...")
 
 	res := resolver.New()
-	updates, err := res.CheckForUpdates(lockFile)
+	res.SetProjectRoot(root)
+	res.SetPatches(project.Patch)
+	res.SetZigVersion(localZigVersion(project))
+	if keep != nil {
+		res.UseLockFile(keep)
+	}
+	res.TrustLockFile(lockFile)
+	if err := updateChecksums(res, lockFile, opts.updateChecksum); err != nil {
+		return err
+	}
+	res.UseVendored(root)
+	res.SetJobs(runtime.NumCPU())
+	if opts.precise != "" {
+		res.SetPrecise(packages[0], opts.precise)
+	}
+
+	resolution, err := res.ResolveWorkspace(project.Members)
 	if err != nil {
-		return fmt.Errorf("failed to check for updates: %w", err)
+		return fmt.Errorf("failed to resolve dependencies: %w", err)
 	}
 
-	if len(updates) == 0 {
+	newLock := resolution.LockFile()
+	newLock.Metadata.Fingerprint = project.Fingerprint()
+	changes := lockChanges(lockFile, newLock)
+
+	if len(changes) == 0 {
+		// yuki.toml may still have changed in a way that keeps every
+		// version, which the lock file has to record.
+		if !opts.dryRun && len(manifest.DiffLockFiles(lockFile, newLock)) > 0 {
+			if err := newLock.Save(root); err != nil {
+				return fmt.Errorf("failed to save lock file: %w", err)
+			}
+		}
 		logger.Success("All dependencies are up to date")
 		return nil
 	}
 
-	
-	if len(args) > 0 {
-		packageName := args[0]
-		var filteredUpdates []resolver.UpdateInfo
-		for _, update := range updates {
-			if update.Name == packageName {
-				filteredUpdates = append(filteredUpdates, update)
-			}
+	if opts.dryRun {
+		printLockChanges(changes)
+		logger.Info("Dry run: yuki.lock was not changed")
+		return nil
+	}
+
+	vendorer := vendor.New()
+	if err := vendorDependencies(vendorer, root, resolution.Dependencies, runtime.NumCPU()); err != nil {
+		return err
+	}
+
+	if err := newLock.Save(root); err != nil {
+		return fmt.Errorf("failed to save lock file: %w", err)
+	}
+
+	// The lock file is shared, so every member's build files are refreshed.
+	for _, member := range project.Members {
+		if project.Workspace {
+			logger.Info("Generating build files for '%s'...", member.Name)
+		}
+		if err := updateMemberFiles(vendorer, project, member, newLock, &opts.features, opts.skipBuildUpdate); err != nil {
+			return err
+		}
+	}
+
+	printLockChanges(changes)
+	logger.Success("Updated %d dependencies", len(changes))
+
+	return nil
+}
+
+// lockChange is one row of the table printed by yuki update.
+type lockChange struct {
+	name string
+	old  string
+	new  string
+}
+
+// lockChanges lists the packages whose locked version or commit differs
+// between two lock files, including packages that were added or removed.
+func lockChanges(old, new *manifest.LockFile) []lockChange {
+	var changes []lockChange
+
+	for _, pkg := range new.Package {
+		prev, exists := old.Find(pkg.Name)
+		switch {
+		case !exists:
+			changes = append(changes, lockChange{name: pkg.Name, old: "-", new: lockedVersionLabel(pkg, false)})
+		case prev.Version != pkg.Version || prev.Source != pkg.Source:
+			changes = append(changes, lockChange{name: pkg.Name, old: lockedVersionLabel(prev, false), new: lockedVersionLabel(pkg, false)})
+		case prev.Commit != pkg.Commit:
+			changes = append(changes, lockChange{name: pkg.Name, old: lockedVersionLabel(prev, true), new: lockedVersionLabel(pkg, true)})
 		}
-		if len(filteredUpdates) == 0 {
-			logger.Info("Package '%s' is already up to date", packageName)
-			return nil
+	}
+
+	for _, pkg := range old.Package {
+		if _, exists := new.Find(pkg.Name); !exists {
+			changes = append(changes, lockChange{name: pkg.Name, old: lockedVersionLabel(pkg, false), new: "-"})
 		}
-		updates = filteredUpdates
 	}
 
-	logger.Info("Available updates:")
-	for _, update := range updates {
-		logger.Info("  %s: %s → %s", update.Name, update.CurrentVersion, update.LatestVersion)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].name < changes[j].name
+	})
+	return changes
+}
+
+// lockedVersionLabel shortens commit hashes used as versions and, with
+// withCommit, names the commit a version was locked at.
+func lockedVersionLabel(pkg manifest.LockedPackage, withCommit bool) string {
+	version := pkg.Version
+	if len(version) == 40 && pkg.Commit == version {
+		return version[:8]
+	}
+	if withCommit && pkg.Commit != "" {
+		commit := pkg.Commit
+		if len(commit) > 8 {
+			commit = commit[:8]
+		}
+		return fmt.Sprintf("%s (%s)", version, commit)
 	}
+	return version
+}
 
-	
-	
-	logger.Info("Run 'yuki add %s@%s' to update specific packages", updates[0].Name, updates[0].LatestVersion)
+func printLockChanges(changes []lockChange) {
+	nameWidth, oldWidth := len("Package"), len("Old")
+	for _, change := range changes {
+		if len(change.name) > nameWidth {
+			nameWidth = len(change.name)
+		}
+		if len(change.old) > oldWidth {
+			oldWidth = len(change.old)
+		}
+	}
 
-	return nil
-}
\ No newline at end of file
+	fmt.Println()
+	fmt.Printf("%-*s  %-*s     %s\n", nameWidth, "Package", oldWidth, "Old", "New")
+	for _, change := range changes {
+		fmt.Printf("%-*s  %-*s  →  %s\n", nameWidth, change.name, oldWidth, change.old, change.new)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"yuki_zpm.org/integrity"
+	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+)
+
+func VerifyCmd() *cobra.Command {
+	var zigFetch bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check vendored packages against yuki.lock",
+		Long:  "Recompute the checksum of every package in yuki_modules and compare it with yuki.lock. Modified, missing and extra files are listed for every package that changed, and the command fails if any did.",
+		// Changed packages are reported as an error, which is not a usage
+		// error.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(zigFetch)
+		},
+	}
+
+	cmd.Flags().BoolVar(&zigFetch, "zig", false, "Also check that 'zig fetch' computes the same package hash for every vendored package")
+
+	return cmd
+}
+
+func runVerify(zigFetch bool) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := manifest.LoadLockFile(project.Root)
+	if err != nil {
+		return fmt.Errorf("failed to load lock file: %w", err)
+	}
+
+	// Packages installed with --mode=zon are fetched and checked by Zig.
+	if _, err := os.Stat(filepath.Join(project.Root, vendor.VendorDir)); os.IsNotExist(err) {
+		logger.Info("No packages are vendored in %s", vendor.VendorDir)
+		return nil
+	}
+
+	logger.Info("Verifying vendored packages...")
+
+	vendorer := vendor.New()
+	verified, changed, disagreed := 0, 0, 0
+
+	for _, pkg := range lockFile.Package {
+		// Path dependencies are linked, not copied, and meant to be edited.
+		if strings.HasPrefix(pkg.Source, manifest.SourcePath+"+") {
+			continue
+		}
+
+		if pkg.Integrity == "" {
+			logger.Warn("'%s' has no checksum in yuki.lock and cannot be verified", pkg.Name)
+			continue
+		}
+
+		drift, err := vendorer.Verify(project.Root, pkg.Name, pkg.Integrity)
+		if err != nil {
+			return err
+		}
+		verified++
+		if drift.Changed() {
+			changed++
+			logger.Error("'%s' %s: %s", pkg.Name, pkg.Version, drift.Summary())
+			printDrift(drift)
+			continue
+		}
+		logger.Debug("'%s' %s matches yuki.lock", pkg.Name, pkg.Version)
+
+		if zigFetch {
+			if err := checkZigFetch(filepath.Join(project.Root, vendor.VendorDir, pkg.Name)); err != nil {
+				disagreed++
+				logger.Error("'%s' %s: %v", pkg.Name, pkg.Version, err)
+			}
+		}
+	}
+
+	vendored, err := vendorer.ListVendoredDependencies(project.Root)
+	if err != nil {
+		return fmt.Errorf("failed to list vendored packages: %w", err)
+	}
+	for _, name := range vendored {
+		if _, locked := lockFile.Find(name); !locked {
+			changed++
+			logger.Error("'%s' is vendored but not in yuki.lock", name)
+		}
+	}
+
+	if changed > 0 {
+		logger.Info("Run 'yuki install' to restore the vendored packages")
+		return fmt.Errorf("%d vendored packages do not match yuki.lock", changed)
+	}
+	if disagreed > 0 {
+		return fmt.Errorf("zig fetch disagrees on %d package hashes", disagreed)
+	}
+
+	logger.Success("All %d vendored packages match yuki.lock", verified)
+	return nil
+}
+
+// checkZigFetch compares the package hash yuki computes for dir with the one
+// printed by `zig fetch`, which also copies the package into Zig's global
+// cache.
+func checkZigFetch(dir string) error {
+	expected, err := integrity.CalculateZigHash(dir)
+	if err != nil {
+		return fmt.Errorf("failed to calculate package hash: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("zig", "fetch", dir)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("zig fetch failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	actual := strings.TrimSpace(string(out))
+	if actual != expected {
+		return fmt.Errorf("zig fetch computes package hash %s, but yuki computes %s", actual, expected)
+	}
+	logger.Debug("zig fetch agrees on package hash %s of %s", actual, dir)
+	return nil
+}
+
+// printDrift lists the files of a package that changed since it was
+// vendored.
+func printDrift(drift vendor.Drift) {
+	for _, group := range []struct {
+		label string
+		files []string
+	}{
+		{"modified:", drift.Modified},
+		{"missing:", drift.MissingFiles},
+		{"extra:", drift.ExtraFiles},
+	} {
+		for _, file := range group.files {
+			fmt.Printf("    %-10s %s\n", group.label, file)
+		}
+	}
+}
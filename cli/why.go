@@ -2,69 +2,214 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/resolver"
+	"yuki_zpm.org/workspace"
 )
 
 func WhyCmd() *cobra.Command {
-	return &cobra.Command{
+	var invert bool
+
+	cmd := &cobra.Command{
 		Use:   "why <package>",
 		Short: "Explain why a package is needed",
-		Long:  "Show the dependency chain that requires a specific package",
+		Long:  "Show every dependency chain from the project to a package, with the requirement each step asks for",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runWhy(args[0])
+			return runWhy(args[0], invert)
 		},
 	}
+
+	cmd.Flags().BoolVarP(&invert, "invert", "i", false, "Show everything that depends on the package as a tree")
+
+	return cmd
 }
 
-func runWhy(packageName string) error {
-	cwd := "."
-	
-	
-	m, err := manifest.Load(cwd)
+// whyPath is a chain of dependencies from a member to a package.
+type whyPath struct {
+	member string
+	edges  []depEdge
+}
+
+func runWhy(packageName string, invert bool) error {
+	project, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := manifest.LoadLockFile(project.Root)
 	if err != nil {
-		logger.Error("No yuki.toml found. Run 'yuki init' first.")
-		return fmt.Errorf("manifest not found: %w", err)
+		return fmt.Errorf("failed to load lock file: %w", err)
+	}
+
+	graph := newDepGraph(project.Root, lockFile)
+	pkg, locked := graph.locked(packageName)
+	if !locked {
+		return whyNotLocked(project, packageName)
 	}
 
 	logger.Info("Analyzing dependency tree for '%s'...", packageName)
 
-	
-	var dependencyType string
-	var found bool
+	if invert {
+		fmt.Printf("%s %s\n", pkg.Name, pkg.Version)
+		printDependents(graph, project.Selected, packageName, "", map[string]bool{packageName: true})
+		return nil
+	}
 
-	if _, exists := m.Dependencies[packageName]; exists {
-		dependencyType = "runtime dependency"
-		found = true
-	} else if _, exists := m.DevDeps[packageName]; exists {
-		dependencyType = "development dependency"
-		found = true
-	} else if _, exists := m.BuildDeps[packageName]; exists {
-		dependencyType = "build dependency"
-		found = true
+	var paths []whyPath
+	for _, member := range project.Selected {
+		for _, edge := range graph.memberEdges(member.Manifest) {
+			findPaths(graph, whyPath{member: member.Name, edges: []depEdge{edge}}, packageName, &paths)
+		}
 	}
 
-	if found {
-		logger.Success("Package '%s' is a direct %s of '%s'", packageName, dependencyType, m.Package.Name)
+	if len(paths) == 0 {
+		logger.Warn("'%s' is in yuki.lock, but none of the selected packages depends on it", packageName)
 		return nil
 	}
 
-	
-	
+	if len(paths) == 1 {
+		logger.Success("'%s' %s is needed through 1 path:", packageName, pkg.Version)
+	} else {
+		logger.Success("'%s' %s is needed through %d paths:", packageName, pkg.Version, len(paths))
+	}
+	for _, path := range paths {
+		fmt.Printf("  %s\n", formatWhyPath(graph, path))
+	}
+
+	return nil
+}
+
+// findPaths extends path, whose last edge has just been taken, until it
+// reaches target. Packages already on the path are not visited again.
+func findPaths(graph *depGraph, path whyPath, target string, paths *[]whyPath) {
+	last := path.edges[len(path.edges)-1]
+	if last.name == target {
+		*paths = append(*paths, whyPath{member: path.member, edges: append([]depEdge{}, path.edges...)})
+		return
+	}
+
+	for _, edge := range graph.packageEdges(last.name) {
+		if onPath(path, edge.name) {
+			continue
+		}
+		path.edges = append(path.edges, edge)
+		findPaths(graph, path, target, paths)
+		path.edges = path.edges[:len(path.edges)-1]
+	}
+}
+
+func onPath(path whyPath, name string) bool {
+	for _, edge := range path.edges {
+		if edge.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatWhyPath(graph *depGraph, path whyPath) string {
+	hops := []string{path.member}
+	for _, edge := range path.edges {
+		version := ""
+		if pkg, locked := graph.locked(edge.name); locked {
+			version = " " + pkg.Version
+		}
+		hops = append(hops, fmt.Sprintf("%s%s (%s)", edge.name, version, describeEdge(edge)))
+	}
+	return strings.Join(hops, " → ")
+}
+
+// describeEdge returns the requirement of an edge and, unless it is a normal
+// dependency, its kind.
+func describeEdge(edge depEdge) string {
+	requirement := "?"
+	if edge.known {
+		requirement = strings.Trim(resolver.DescribeSpec(edge.dep), "()")
+	}
+	if edge.kind != manifest.KindNormal {
+		return requirement + ", " + edge.kind
+	}
+	return requirement
+}
+
+// printDependents prints the packages and members depending on name as a
+// tree below it. Members are the leaves.
+func printDependents(graph *depGraph, members []workspace.Member, name, prefix string, seen map[string]bool) {
+	type dependent struct {
+		name     string
+		version  string
+		edge     depEdge
+		isMember bool
+	}
+
+	var dependents []dependent
+	shown := make(map[string]bool)
+	for _, pkg := range graph.sortedPackages() {
+		for _, edge := range graph.packageEdges(pkg.Name) {
+			if edge.name == name {
+				dependents = append(dependents, dependent{name: pkg.Name, version: pkg.Version, edge: edge})
+				shown[pkg.Name] = true
+			}
+		}
+	}
+	for _, member := range members {
+		// A member that other members depend on by path is already shown
+		// as a package, together with its dependents.
+		if shown[member.Name] {
+			continue
+		}
+		for _, edge := range graph.memberEdges(member.Manifest) {
+			if edge.name == name {
+				dependents = append(dependents, dependent{name: member.Name, edge: edge, isMember: true})
+			}
+		}
+	}
+
+	for i, d := range dependents {
+		branch, indent := "├── ", "│   "
+		if i == len(dependents)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		label := d.name
+		if d.version != "" {
+			label += " " + d.version
+		}
+		fmt.Printf("%s%s%s (requires %s)\n", prefix, branch, label, describeEdge(d.edge))
+
+		if d.isMember || seen[d.name] {
+			continue
+		}
+		seen[d.name] = true
+		printDependents(graph, members, d.name, prefix+indent, seen)
+		delete(seen, d.name)
+	}
+}
+
+// whyNotLocked explains a package that is missing from yuki.lock.
+func whyNotLocked(project *workspace.Project, packageName string) error {
+	for _, member := range project.Selected {
+		if _, exists := member.Manifest.GetAllDependencies()[packageName]; exists {
+			logger.Warn("'%s' is a dependency of '%s' but is not in yuki.lock yet", packageName, member.Name)
+			logger.Info("Run 'yuki install' to resolve it")
+			return nil
+		}
+	}
+
 	logger.Warn("Package '%s' is not found in the dependency tree", packageName)
-	logger.Info("This could mean:")
-	logger.Info("  - The package is not used by this project")
-	logger.Info("  - It's a transitive dependency (not yet fully implemented)")
-	logger.Info("  - There's a typo in the package name")
 
-	
-	allDeps := m.GetAllDependencies()
+	var allDeps []string
+	for _, member := range project.Selected {
+		allDeps = append(allDeps, member.Manifest.DependencyNames()...)
+	}
 	if len(allDeps) > 0 {
-		logger.Info("\nAvailable dependencies:")
-		for name := range allDeps {
+		logger.Info("\nDirect dependencies:")
+		for _, name := range allDeps {
 			logger.Info("  - %s", name)
 		}
 	}
@@ -3,21 +3,27 @@ package fetch
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sync"
 
 	"yuki_zpm.org/cache"
-	"yuki_zpm.org/github"
 	"yuki_zpm.org/integrity"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/source"
 	"yuki_zpm.org/utils"
 )
 
+// Fetcher may be shared between goroutines. Fetches of the same package are
+// serialized so a repository is never cloned into the same directory twice
+// at once.
 type Fetcher struct {
-	cache        *cache.Cache
-	githubClient *github.Client
+	cache   *cache.Cache
+	offline bool
+	refresh map[string]bool
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
 }
 
 type FetchResult struct {
@@ -25,213 +31,163 @@ type FetchResult struct {
 	Checksum  string
 	Version   string
 	CommitSHA string
+	// ZigHash is the package hash Zig computes for the contents.
+	ZigHash   string
 }
 
-func NewFetcher() *Fetcher {
-	return &Fetcher{
-		cache        : cache.New(),
-		githubClient : github.NewClient(),
+// Integrity returns the integrity string of the fetched contents for
+// algorithm, or an empty string if it was not calculated.
+func (r *FetchResult) Integrity(algorithm string) string {
+	switch {
+	case algorithm == integrity.SHA256 && r.Checksum != "":
+		return integrity.Format(algorithm, r.Checksum)
+	case algorithm == integrity.ZigHash && r.ZigHash != "":
+		return integrity.Format(algorithm, r.ZigHash)
+	default:
+		return ""
 	}
 }
 
-func (f *Fetcher) checkTagExists(owner, repo, tag string) (bool, error) {
-	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
-	
-	cmd := exec.Command("git", "ls-remote", "--tags", repoURL, tag)
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
+func NewFetcher() *Fetcher {
+	return &Fetcher{
+		cache : cache.New(),
+		refresh : make(map[string]bool),
+		locks : make(map[string]*sync.Mutex),
 	}
-	
-	return strings.Contains(string(output), tag), nil
 }
 
-func (f *Fetcher) getLatestReleaseTag(owner, repo string) (string, error) {
-	release, err := f.githubClient.GetLatestRelease(owner, repo)
-	if err != nil {
-		return "", err
+func (f *Fetcher) lock(key string) func() {
+	f.mu.Lock()
+	l, exists := f.locks[key]
+	if !exists {
+		l = &sync.Mutex{}
+		f.locks[key] = l
 	}
-	return release.TagName, nil
-}
+	f.mu.Unlock()
 
-func (f *Fetcher) getLatestCommitSHA(owner, repo string) (string, error) {
-	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+	l.Lock()
+	return l.Unlock
+}
 
-	cmd := exec.Command("git", "ls-remote", repoURL, "refs/heads/main")
-	output, err := cmd.Output()
-	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
-		parts := strings.Fields(string(output))
-		if len(parts) > 0 {
-			return parts[0], nil
-		}
-	}
+// SetOffline restricts the fetcher to packages that are already cached.
+func (f *Fetcher) SetOffline(offline bool) {
+	f.offline = offline
+}
 
-	cmd = exec.Command("git", "ls-remote", repoURL, "refs/heads/master")
-	output, err = cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get latest commit: %w", err)
-	}
-	
-	parts := strings.Fields(string(output))
-	if len(parts) == 0 {
-		return "", fmt.Errorf("no commit found in master branch")
-	}
-	
-	return parts[0], nil
+// SetRefresh makes the fetcher ignore cached copies of the package name
+// and fetch it from its source again. It must be called before fetching.
+func (f *Fetcher) SetRefresh(name string) {
+	f.refresh[name] = true
 }
 
-func (f *Fetcher) cloneRepository(owner, repo, ref string) (string, error) {
-	repoURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+// checkout fetches rev of src into the cache and returns its directory and
+// the commit that was checked out.
+func (f *Fetcher) checkout(src source.Source, rev source.Revision) (string, string, error) {
+	targetDir := filepath.Join(f.cache.GetCacheDir(), "repos", filepath.FromSlash(src.Key()), rev.Ref)
+	defer f.lock(targetDir)()
 
-	targetDir := filepath.Join(f.cache.GetCacheDir(), "repos", owner, repo, ref)
 	if err := os.RemoveAll(targetDir); err != nil {
-		return "", fmt.Errorf("failed to clean target directory: %w", err)
+		return "", "", fmt.Errorf("failed to clean target directory: %w", err)
 	}
 	
 	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache directory: %w", err)
+		return "", "", fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	logger.Debug("Cloning %s@%s to %s", repoURL, ref, targetDir)
-
-	if len(ref) == 40 && utils.IsHexString(ref) {
-		cmd := exec.Command("git", "clone", repoURL, targetDir)
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("failed to clone repository: %w", err)
-		}
-
-		cmd = exec.Command("git", "checkout", ref)
-		cmd.Dir = targetDir
-		if err := cmd.Run(); err != nil {
-			return "", fmt.Errorf("failed to checkout commit '%s': %w", ref, err)
-		}
-	} else {
-		cmd := exec.Command("git", "clone", "--depth=1", "--branch", ref, repoURL, targetDir)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			if strings.Contains(string(output), "does not exist") || strings.Contains(string(output), "not found") {
-				logger.Debug("Shallow clone failed, trying full clone: %s", string(output))
-
-				os.RemoveAll(targetDir)
-				
-				cmd = exec.Command("git", "clone", repoURL, targetDir)
-				if err := cmd.Run(); err != nil {
-					return "", fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, output)
-				}
-
-				cmd = exec.Command("git", "checkout", ref)
-				cmd.Dir = targetDir
-				if err := cmd.Run(); err != nil {
-					return "", fmt.Errorf("failed to checkout ref '%s': %w", ref, err)
-				}
-			} else {
-				return "", fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, output)
-			}
-		}
-	}
-	
-	gitDir := filepath.Join(targetDir, ".git")
-	os.RemoveAll(gitDir)
-
-	return targetDir, nil
-}
-
-func (f *Fetcher) determineRefWithValidation(owner, repo string, dep manifest.Dependency) (string, string, string, error) {
-	if dep.Rev != "" {
-		return dep.Rev, dep.Rev, dep.Rev, nil
-	}
-	if dep.Tag != "" {
-		return dep.Tag, dep.Tag, "", nil
-	}
-	if dep.Branch != "" {
-		return dep.Branch, dep.Branch, "", nil
-	}
-	
-	if dep.UseLatestCommit {
-		logger.Info("Fetching latest commit...")
-		commitSHA, err := f.getLatestCommitSHA(owner, repo)
-		if err != nil {
-			return "", "", "", fmt.Errorf("failed to get latest commit: %w", err)
-		}
-		logger.Debug("Latest commit SHA: %s", commitSHA)
-		return commitSHA, commitSHA, commitSHA, nil
-	}
-	
-	if dep.Version != "" {
-		if dep.Version == "latest" {
-			latestTag, err := f.getLatestReleaseTag(owner, repo)
-			if err != nil {
-				return "", "", "", fmt.Errorf("failed to get latest release: %w", err)
+	commitSHA, err := src.Fetch(rev, targetDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Branches move, so a checkout is kept under its commit. Otherwise the
+	// next fetch of the branch would replace what an older commit's cache
+	// entry points to.
+	if commitSHA != "" && commitSHA != rev.Ref {
+		commitDir := filepath.Join(f.cache.GetCacheDir(), "repos", filepath.FromSlash(src.Key()), commitSHA)
+		defer f.lock(commitDir)()
+
+		// Another fetch may have checked out the same commit already, and
+		// its caller may still be reading it, so that checkout is kept and
+		// the new one dropped.
+		if _, err := os.Stat(commitDir); err == nil {
+			if err := os.RemoveAll(targetDir); err != nil {
+				return "", "", fmt.Errorf("failed to clean target directory: %w", err)
 			}
-			logger.Debug("Using latest release tag: %s", latestTag)
-			return latestTag, latestTag, "", nil
+			return commitDir, commitSHA, nil
 		}
-		
-		version := strings.TrimPrefix(dep.Version, "^")
-		version = strings.TrimPrefix(version, "~")
-		version = strings.TrimPrefix(version, "=")
-		
-		possibleTags := []string{
-			version,        // example 0.10.0
-			"v" + version,  // example v0.10.0
+		if err := os.Rename(targetDir, commitDir); err != nil {
+			return "", "", fmt.Errorf("failed to move checkout of %s: %w", rev.Ref, err)
 		}
-		
-		for _, tag := range possibleTags {
-			exists, err := f.checkTagExists(owner, repo, tag)
-			if err != nil {
-				logger.Debug("Failed to check tag %s: %v", tag, err)
-				continue
-			}
-			if exists {
-				return tag, dep.Version, "", nil
-			}
-		}
-		
-		return "", "", "", fmt.Errorf("no matching tag found for version %s (tried: %s)", dep.Version, strings.Join(possibleTags, ", "))
+		targetDir = commitDir
 	}
 
-	latestTag, err := f.getLatestReleaseTag(owner, repo)
-	if err == nil && latestTag != "" {
-		logger.Debug("Using latest release tag: %s", latestTag)
-		return latestTag, latestTag, "", nil
-	}
+	return targetDir, commitSHA, nil
+}
 
-	logger.Debug("No releases found, using latest commit")
-	commitSHA, err := f.getLatestCommitSHA(owner, repo)
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to get latest commit: %w", err)
+// movingRef reports whether dep follows a branch or the latest release,
+// whose commit changes over time, rather than a fixed tag, version or rev.
+func movingRef(dep manifest.Dependency) bool {
+	if dep.Branch != "" || dep.UseLatestCommit {
+		return true
 	}
-	return commitSHA, commitSHA, commitSHA, nil
+	return dep.Rev == "" && dep.Tag == "" && (dep.Version == "" || dep.Version == "latest")
 }
 
 func (f *Fetcher) FetchDependency(name string, dep manifest.Dependency) (*FetchResult, error) {
-	logger.Info("Fetching dependency '%s'", name)
-	
-	owner, repo, err := github.ParseRepoURL(dep.Git)
+	src, err := source.New(dep)
 	if err != nil {
-		return nil, fmt.Errorf("invalid git URL for '%s': %w", name, err)
+		return nil, fmt.Errorf("invalid source for '%s': %w", name, err)
 	}
 	
-	cacheKey := utils.GenerateCacheKey(owner, repo, dep)
+	if local, ok := src.(source.Local); ok {
+		return fetchLocal(name, src, local, dep)
+	}
 
-	if cached, exists := f.cache.Get(cacheKey); exists {
+	cacheKey := utils.GenerateCacheKey(src.Key(), dep)
+	defer f.lock(cacheKey)()
+
+	// A moving ref is only served from the cache when the network cannot
+	// be used; otherwise it is fetched again to pick up new commits.
+	if cached, exists := f.cache.Get(cacheKey); exists && (f.offline || !movingRef(dep)) && !f.refresh[name] {
 		logger.Debug("Using cached version of '%s'", name)
+		version := cached.Version
+		if dep.Rev != "" {
+			// The entry may have been stored for a tag pointing to the
+			// same commit, but a rev dependency is versioned by its rev.
+			version = dep.Rev
+		}
+		if cached.ZigHash == "" {
+			zigHash, err := integrity.CalculateZigHash(cached.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to calculate package hash for '%s': %w", name, err)
+			}
+			cached.ZigHash = zigHash
+			f.cache.Set(cacheKey, cached)
+		}
 		return &FetchResult{
 			Path:      cached.Path,
 			Checksum:  cached.Checksum,
-			Version:   cached.Version,
+			Version:   version,
 			CommitSHA: cached.CommitSHA,
+			ZigHash:   cached.ZigHash,
 		}, nil
 	}
+
+	if f.offline {
+		return nil, fmt.Errorf("'%s' is not available in the cache and network access is disabled", name)
+	}
+
+	logger.Debug("Fetching dependency '%s'", name)
 	
-	ref, resolvedVersion, commitSHA, err := f.determineRefWithValidation(owner, repo, dep)
+	rev, err := src.ResolveRef(dep)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine reference for '%s': %w", name, err)
 	}
+	resolvedVersion := rev.Version
 
-	repoPath, err := f.cloneRepository(owner, repo, ref)
+	repoPath, commitSHA, err := f.checkout(src, rev)
 	if err != nil {
-		return nil, fmt.Errorf("failed to clone repository '%s/%s': %w", owner, repo, err)
+		return nil, fmt.Errorf("failed to fetch '%s' from %s: %w", name, src.Describe(), err)
 	}
 
 	checksum, err := integrity.CalculateDirectoryChecksum(repoPath)
@@ -239,24 +195,55 @@ func (f *Fetcher) FetchDependency(name string, dep manifest.Dependency) (*FetchR
 		return nil, fmt.Errorf("failed to calculate checksum for '%s': %w", name, err)
 	}
 
+	zigHash, err := integrity.CalculateZigHash(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate package hash for '%s': %w", name, err)
+	}
+
 	result := &FetchResult{
 		Path:      repoPath,
 		Checksum:  checksum,
 		Version:   resolvedVersion,
 		CommitSHA: commitSHA,
+		ZigHash:   zigHash,
 	}
 
-	f.cache.Set(cacheKey, cache.Entry{
+	entry := cache.Entry{
 		Path:      repoPath,
 		Checksum:  checksum,
 		Version:   resolvedVersion,
 		CommitSHA: commitSHA,
-	})
+		ZigHash:   zigHash,
+	}
+	f.cache.Set(cacheKey, entry)
+
+	// Also index the checkout by commit so locked installs can find it
+	// without asking the remote which commit a tag or branch points to.
+	commitKey := utils.GenerateCacheKey(src.Key(), manifest.Dependency{Rev: commitSHA})
+	if commitSHA != "" && commitKey != cacheKey {
+		f.cache.Set(commitKey, entry)
+	}
 
 	if commitSHA != "" {
-		logger.Success("Successfully fetched '%s@%s' (commit: %s)", name, resolvedVersion, commitSHA[:8])
+		logger.Debug("Fetched '%s@%s' (commit: %s)", name, resolvedVersion, commitSHA[:8])
 	} else {
-		logger.Success("Successfully fetched '%s@%s'", name, resolvedVersion)
+		logger.Debug("Fetched '%s@%s'", name, resolvedVersion)
 	}
 	return result, nil
 }
+
+// fetchLocal uses a package that lives on disk in place. It works offline and
+// bypasses the cache, so edits to the package are picked up immediately. The
+// contents are expected to change, so no checksum is recorded.
+func fetchLocal(name string, src source.Source, local source.Local, dep manifest.Dependency) (*FetchResult, error) {
+	rev, err := src.ResolveRef(dep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", name, err)
+	}
+
+	logger.Debug("Using '%s@%s' from %s", name, rev.Version, local.Dir())
+	return &FetchResult{
+		Path:    local.Dir(),
+		Version: rev.Version,
+	}, nil
+}
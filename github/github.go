@@ -97,7 +97,7 @@ func ParseRepoURL(repoURL string) (owner, repo string, err error) {
 		}
 
 		if u.Host != "github.com" {
-			return "", "", fmt.Errorf("only GitHub repositories are supported")
+			return "", "", fmt.Errorf("%s is not a GitHub repository", repoURL)
 		}
 
 		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
@@ -257,6 +257,27 @@ func (c *Client) GetAvailableVersions(owner, repo string) ([]semver.Version, err
 	return versions, nil
 }
 
+// GetUnstableVersions returns the versions whose GitHub release is a draft or
+// marked as a prerelease, keyed by their normalized version string.
+func (c *Client) GetUnstableVersions(owner, repo string) (map[string]bool, error) {
+	releases, err := c.GetReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	unstable := make(map[string]bool)
+	for _, release := range releases {
+		if !release.Draft && !release.Prerelease {
+			continue
+		}
+		if version, err := semver.ParseVersion(release.TagName); err == nil {
+			unstable[version.String()] = true
+		}
+	}
+
+	return unstable, nil
+}
+
 func (c *Client) GetLatestRelease(owner, repo string) (*Release, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", owner, repo)
 	
@@ -10,6 +10,48 @@ import (
         "strings"
 )
 
+// SHA256 is the algorithm of the directory checksums calculated by
+// CalculateDirectoryChecksum, the first checksum scheme of yuki.lock.
+const SHA256 = "sha256"
+
+// Format returns an integrity string such as "sha256-<hex>", which names
+// the algorithm a checksum was calculated with.
+func Format(algorithm, checksum string) string {
+        return algorithm + "-" + checksum
+}
+
+// Parse splits an integrity string into its algorithm and checksum. A bare
+// checksum, as written by version 1 lock files, is a SHA-256 checksum.
+func Parse(value string) (string, string, error) {
+        algorithm, checksum, found := strings.Cut(value, "-")
+        if !found {
+                algorithm, checksum = SHA256, value
+        }
+        if algorithm == "" || checksum == "" {
+                return "", "", fmt.Errorf("invalid integrity '%s'", value)
+        }
+        return algorithm, checksum, nil
+}
+
+// Calculate returns the integrity string of the directory dirPath, using
+// algorithm SHA256 or ZigHash.
+func Calculate(algorithm, dirPath string) (string, error) {
+        var checksum string
+        var err error
+        switch algorithm {
+        case SHA256:
+                checksum, err = CalculateDirectoryChecksum(dirPath)
+        case ZigHash:
+                checksum, err = CalculateZigHash(dirPath)
+        default:
+                return "", fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+        }
+        if err != nil {
+                return "", err
+        }
+        return Format(algorithm, checksum), nil
+}
+
 func CalculateFileChecksum(filePath string) (string, error) {
         file, err := os.Open(filePath)
         if err != nil {
@@ -27,9 +69,30 @@ func CalculateFileChecksum(filePath string) (string, error) {
 
 
 func CalculateDirectoryChecksum(dirPath string) (string, error) {
-        var files []string
-        
-        
+        files, err := FileChecksums(dirPath)
+        if err != nil {
+                return "", err
+        }
+        return DirectoryChecksum(files), nil
+}
+
+// symlinkChecksum hashes the target of the symlink at linkPath, so links
+// that dangle or point at directories are hashed like any other file.
+func symlinkChecksum(linkPath string) (string, error) {
+        target, err := os.Readlink(linkPath)
+        if err != nil {
+                return "", err
+        }
+        return fmt.Sprintf("%x", sha256.Sum256([]byte(filepath.ToSlash(target)))), nil
+}
+
+// FileChecksums returns the checksum of every file below dirPath, keyed by
+// its path relative to dirPath. Like CalculateDirectoryChecksum it skips
+// .git directories. Symlinks are hashed by their target rather than
+// followed, as CalculateZigHash does.
+func FileChecksums(dirPath string) (map[string]string, error) {
+        files := make(map[string]string)
+
         err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
                 if err != nil {
                         return err
@@ -45,41 +108,53 @@ func CalculateDirectoryChecksum(dirPath string) (string, error) {
                         return nil
                 }
                 
-                files = append(files, path)
+                relPath, err := filepath.Rel(dirPath, path)
+                if err != nil {
+                        return err
+                }
+                
+                var fileHash string
+                if info.Mode()&os.ModeSymlink != 0 {
+                        fileHash, err = symlinkChecksum(path)
+                } else {
+                        fileHash, err = CalculateFileChecksum(path)
+                }
+                if err != nil {
+                        return fmt.Errorf("failed to hash file %s: %w", path, err)
+                }
+                
+                files[relPath] = fileHash
                 return nil
         })
         
         if err != nil {
-                return "", err
+                return nil, err
         }
         
-        
-        sort.Strings(files)
+        return files, nil
+}
+
+// DirectoryChecksum combines the checksums returned by FileChecksums into
+// the checksum of the directory.
+func DirectoryChecksum(files map[string]string) string {
+        paths := make([]string, 0, len(files))
+        for path := range files {
+                paths = append(paths, path)
+        }
+        sort.Strings(paths)
         
         hash := sha256.New()
         
         
-        for _, filePath := range files {
-                relPath, err := filepath.Rel(dirPath, filePath)
-                if err != nil {
-                        return "", err
-                }
-                
-                
+        for _, relPath := range paths {
                 hash.Write([]byte(relPath))
                 hash.Write([]byte{0}) 
                 
-                
-                fileHash, err := CalculateFileChecksum(filePath)
-                if err != nil {
-                        return "", fmt.Errorf("failed to hash file %s: %w", filePath, err)
-                }
-                
-                hash.Write([]byte(fileHash))
+                hash.Write([]byte(files[relPath]))
                 hash.Write([]byte{0}) 
         }
         
-        return fmt.Sprintf("%x", hash.Sum(nil)), nil
+        return fmt.Sprintf("%x", hash.Sum(nil))
 }
 
 func contains(slice []string, item string) bool {
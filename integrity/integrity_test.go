@@ -0,0 +1,145 @@
+package integrity
+
+import (
+        "os"
+        "path/filepath"
+        "testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+        t.Helper()
+        for name, content := range files {
+                path := filepath.Join(dir, filepath.FromSlash(name))
+                if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+                        t.Fatal(err)
+                }
+                if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+                        t.Fatal(err)
+                }
+        }
+}
+
+func TestParse(t *testing.T) {
+        tests := []struct {
+                value     string
+                algorithm string
+                checksum  string
+        }{
+                {"sha256-abc", SHA256, "abc"},
+                {"zig-1220ab", ZigHash, "1220ab"},
+                {"abc", SHA256, "abc"},
+        }
+        for _, tt := range tests {
+                algorithm, checksum, err := Parse(tt.value)
+                if err != nil || algorithm != tt.algorithm || checksum != tt.checksum {
+                        t.Errorf("Parse(%q) = %q, %q, %v", tt.value, algorithm, checksum, err)
+                }
+                if tt.value != tt.checksum && Format(algorithm, checksum) != tt.value {
+                        t.Errorf("Format(%q, %q) = %q, want %q", algorithm, checksum, Format(algorithm, checksum), tt.value)
+                }
+        }
+
+        for _, value := range []string{"", "-abc", "sha256-"} {
+                if _, _, err := Parse(value); err == nil {
+                        t.Errorf("Parse(%q) succeeded, want an error", value)
+                }
+        }
+}
+
+func TestFileChecksums(t *testing.T) {
+        dir := t.TempDir()
+        writeFiles(t, dir, map[string]string{
+                "build.zig":      "a",
+                "src/main.zig":   "b",
+                ".git/HEAD":      "ref",
+                "sub/.git/index": "x",
+        })
+
+        files, err := FileChecksums(dir)
+        if err != nil {
+                t.Fatalf("FileChecksums: %v", err)
+        }
+        if len(files) != 2 || files["build.zig"] == "" || files[filepath.Join("src", "main.zig")] == "" {
+                t.Errorf("unexpected files %v", files)
+        }
+
+        checksum, err := CalculateDirectoryChecksum(dir)
+        if err != nil {
+                t.Fatalf("CalculateDirectoryChecksum: %v", err)
+        }
+        if checksum != DirectoryChecksum(files) {
+                t.Errorf("CalculateDirectoryChecksum = %s, want %s", checksum, DirectoryChecksum(files))
+        }
+
+        writeFiles(t, dir, map[string]string{"src/main.zig": "c"})
+        if changed, _ := CalculateDirectoryChecksum(dir); changed == checksum {
+                t.Errorf("editing a file kept the checksum %s", checksum)
+        }
+}
+
+func TestFileChecksumsSymlinks(t *testing.T) {
+        dir := t.TempDir()
+        writeFiles(t, dir, map[string]string{"src/main.zig": "b"})
+        for link, target := range map[string]string{
+                "dangling": "missing.zig",
+                "srcdir":   "src",
+                "main.zig": "src/main.zig",
+        } {
+                if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+                        t.Skipf("symlinks are not supported: %v", err)
+                }
+        }
+
+        files, err := FileChecksums(dir)
+        if err != nil {
+                t.Fatalf("FileChecksums: %v", err)
+        }
+        for _, link := range []string{"dangling", "srcdir", "main.zig"} {
+                if files[link] == "" {
+                        t.Errorf("symlink %s was not hashed: %v", link, files)
+                }
+        }
+        if files["main.zig"] == files[filepath.Join("src", "main.zig")] {
+                t.Errorf("the symlink was hashed by the contents of its target")
+        }
+        if _, followed := files[filepath.Join("srcdir", "main.zig")]; followed {
+                t.Errorf("the directory symlink was followed: %v", files)
+        }
+
+        // Pointing a link elsewhere changes the checksum.
+        before := DirectoryChecksum(files)
+        os.Remove(filepath.Join(dir, "dangling"))
+        if err := os.Symlink("other.zig", filepath.Join(dir, "dangling")); err != nil {
+                t.Fatal(err)
+        }
+        if after, err := CalculateDirectoryChecksum(dir); err != nil || after == before {
+                t.Errorf("retargeting a symlink gave %s, %v", after, err)
+        }
+}
+
+func TestVerifyChecksum(t *testing.T) {
+        dir := t.TempDir()
+        writeFiles(t, dir, map[string]string{"a.txt": "hello"})
+
+        fileSum, err := CalculateFileChecksum(filepath.Join(dir, "a.txt"))
+        if err != nil {
+                t.Fatal(err)
+        }
+        if fileSum != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+                t.Errorf("CalculateFileChecksum = %s", fileSum)
+        }
+        if err := VerifyChecksum(filepath.Join(dir, "a.txt"), fileSum); err != nil {
+                t.Errorf("VerifyChecksum of the file: %v", err)
+        }
+
+        dirSum, err := CalculateDirectoryChecksum(dir)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if err := VerifyChecksum(dir, dirSum); err != nil {
+                t.Errorf("VerifyChecksum of the directory: %v", err)
+        }
+        if err := VerifyChecksum(dir, fileSum); err == nil {
+                t.Errorf("VerifyChecksum with a wrong checksum succeeded")
+        }
+}
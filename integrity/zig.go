@@ -0,0 +1,240 @@
+package integrity
+
+import (
+        "crypto/sha256"
+        "errors"
+        "fmt"
+        "io"
+        "io/fs"
+        "os"
+        "path"
+        "path/filepath"
+        "sort"
+        "strconv"
+        "strings"
+)
+
+// ZigHash is the algorithm of package hashes as Zig's package manager
+// computes them and writes them to build.zig.zon.
+const ZigHash = "zig"
+
+// zigHashPrefix is the multihash header of a SHA-256 digest, which every
+// package hash of Zig 0.12 and 0.13 starts with.
+const zigHashPrefix = "1220"
+
+// zonFile is the Zig package manifest whose .paths select the files of a
+// package.
+const zonFile = "build.zig.zon"
+
+type zigHashedFile struct {
+        path string
+        hash [sha256.Size]byte
+}
+
+// CalculateZigHash returns the hash `zig fetch` prints for the package in
+// dirPath. Only the files the .paths of its build.zig.zon include are
+// hashed, with '/' separated paths. Symlinks are hashed by their target
+// rather than followed. Zig hashes the executable bit of every file as
+// unset (see ziglang/zig#17463), and so does this function.
+func CalculateZigHash(dirPath string) (string, error) {
+        includePaths, err := ZonPaths(dirPath)
+        if err != nil {
+                return "", err
+        }
+        include := make(map[string]bool)
+        for _, p := range includePaths {
+                include[p] = true
+        }
+
+        var files []zigHashedFile
+        err = filepath.WalkDir(dirPath, func(fsPath string, entry fs.DirEntry, err error) error {
+                if err != nil {
+                        return err
+                }
+                if entry.IsDir() {
+                        return nil
+                }
+
+                relPath, err := filepath.Rel(dirPath, fsPath)
+                if err != nil {
+                        return err
+                }
+                normalized := filepath.ToSlash(relPath)
+                if !zigIncludes(include, normalized) {
+                        return nil
+                }
+
+                hash, err := zigHashFile(fsPath, normalized, entry.Type())
+                if err != nil {
+                        return fmt.Errorf("failed to hash file %s: %w", fsPath, err)
+                }
+                files = append(files, zigHashedFile{path: normalized, hash: hash})
+                return nil
+        })
+        if err != nil {
+                return "", err
+        }
+
+        sort.Slice(files, func(i, j int) bool {
+                return files[i].path < files[j].path
+        })
+
+        hash := sha256.New()
+        for _, file := range files {
+                hash.Write(file.hash[:])
+        }
+
+        return fmt.Sprintf("%s%x", zigHashPrefix, hash.Sum(nil)), nil
+}
+
+func zigHashFile(fsPath, normalized string, mode fs.FileMode) ([sha256.Size]byte, error) {
+        var sum [sha256.Size]byte
+
+        hash := sha256.New()
+        hash.Write([]byte(normalized))
+
+        if mode&fs.ModeSymlink != 0 {
+                target, err := os.Readlink(fsPath)
+                if err != nil {
+                        return sum, err
+                }
+                hash.Write([]byte(filepath.ToSlash(target)))
+        } else {
+                file, err := os.Open(fsPath)
+                if err != nil {
+                        return sum, err
+                }
+                defer file.Close()
+
+                hash.Write([]byte{0, 0})
+                if _, err := io.Copy(hash, file); err != nil {
+                        return sum, err
+                }
+        }
+
+        copy(sum[:], hash.Sum(nil))
+        return sum, nil
+}
+
+// zigIncludes reports whether a file is part of the package, in the same
+// way as Zig: when .paths is given, a file is included if it or one of its
+// parent directories is listed.
+func zigIncludes(include map[string]bool, normalized string) bool {
+        if len(include) == 0 || include[""] || include["."] || include[normalized] {
+                return true
+        }
+        for dir := path.Dir(normalized); dir != "." && dir != "/"; dir = path.Dir(dir) {
+                if include[dir] {
+                        return true
+                }
+        }
+        return false
+}
+
+// ZonPaths returns the .paths listed in the build.zig.zon of dirPath,
+// normalized to '/' separated paths without a trailing slash. It returns
+// nil when the package has no build.zig.zon or the file has no .paths.
+func ZonPaths(dirPath string) ([]string, error) {
+        data, err := os.ReadFile(filepath.Join(dirPath, zonFile))
+        if errors.Is(err, os.ErrNotExist) {
+                return nil, nil
+        }
+        if err != nil {
+                return nil, fmt.Errorf("failed to read %s: %w", zonFile, err)
+        }
+
+        paths, err := parseZonPaths(string(data))
+        if err != nil {
+                return nil, fmt.Errorf("failed to parse %s: %w", zonFile, err)
+        }
+        return paths, nil
+}
+
+// parseZonPaths extracts the strings of the `.paths = .{ ... }` field. It
+// understands just enough of ZON for that: comments, string literals and
+// nested braces.
+func parseZonPaths(src string) ([]string, error) {
+        src = stripZonComments(src)
+
+        start := strings.Index(src, ".paths")
+        for start >= 0 {
+                rest := strings.TrimLeft(src[start+len(".paths"):], " \t\r\n")
+                if strings.HasPrefix(rest, "=") {
+                        src = strings.TrimLeft(rest[1:], " \t\r\n")
+                        break
+                }
+                next := strings.Index(src[start+1:], ".paths")
+                if next < 0 {
+                        start = -1
+                } else {
+                        start += 1 + next
+                }
+        }
+        if start < 0 {
+                return nil, nil
+        }
+        if !strings.HasPrefix(src, ".{") {
+                return nil, fmt.Errorf(".paths must be a list of strings")
+        }
+
+        var paths []string
+        for i := 2; i < len(src); i++ {
+                switch c := src[i]; {
+                case c == '}':
+                        return paths, nil
+                case c == '"':
+                        end := i + 1
+                        for end < len(src) && src[end] != '"' {
+                                if src[end] == '\\' {
+                                        end++
+                                }
+                                end++
+                        }
+                        if end >= len(src) {
+                                return nil, fmt.Errorf("unterminated string in .paths")
+                        }
+                        value, err := strconv.Unquote(src[i : end+1])
+                        if err != nil {
+                                return nil, fmt.Errorf("invalid string %s in .paths", src[i:end+1])
+                        }
+                        paths = append(paths, normalizeZonPath(value))
+                        i = end
+                case c == ',' || c == ' ' || c == '\t' || c == '\r' || c == '\n':
+                default:
+                        return nil, fmt.Errorf("unexpected '%c' in .paths", c)
+                }
+        }
+        return nil, fmt.Errorf("unterminated .paths")
+}
+
+// stripZonComments removes // comments outside of string literals.
+func stripZonComments(src string) string {
+        var sb strings.Builder
+        inString := false
+        for i := 0; i < len(src); i++ {
+                c := src[i]
+                switch {
+                case inString && c == '\\' && i+1 < len(src):
+                        sb.WriteByte(c)
+                        i++
+                        c = src[i]
+                case c == '"':
+                        inString = !inString
+                case !inString && c == '/' && i+1 < len(src) && src[i+1] == '/':
+                        for i < len(src) && src[i] != '\n' {
+                                i++
+                        }
+                        if i < len(src) {
+                                sb.WriteByte('\n')
+                        }
+                        continue
+                }
+                sb.WriteByte(c)
+        }
+        return sb.String()
+}
+
+func normalizeZonPath(p string) string {
+        p = strings.TrimPrefix(filepath.ToSlash(p), "./")
+        return strings.TrimSuffix(p, "/")
+}
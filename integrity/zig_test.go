@@ -0,0 +1,113 @@
+package integrity
+
+import (
+        "os"
+        "path/filepath"
+        "reflect"
+        "testing"
+)
+
+const testZon = `.{
+    .name = "pkg",
+    .version = "0.1.0",
+    // .paths = .{"nothing"},
+    .paths = .{
+        "build.zig",
+        "build.zig.zon",
+        "src/",
+    },
+}
+`
+
+func TestCalculateZigHash(t *testing.T) {
+        dir := t.TempDir()
+        writeFiles(t, dir, map[string]string{
+                "build.zig":    "const std = @import(\"std\");\n",
+                "src/main.zig": "pub fn main() void {}\n",
+        })
+
+        // Without a build.zig.zon every file is part of the package.
+        hash, err := CalculateZigHash(dir)
+        if err != nil {
+                t.Fatalf("CalculateZigHash: %v", err)
+        }
+        if want := "12201dcb2dfd1463a2b820f4105b196a92be84ec58ac626b9e9576c2c2e6a9ba26b7"; hash != want {
+                t.Errorf("CalculateZigHash = %s, want %s", hash, want)
+        }
+
+        // Files outside .paths are left out, symlinks are hashed by their
+        // target and the executable bit is ignored.
+        writeFiles(t, dir, map[string]string{
+                "build.zig.zon": testZon,
+                "README.md":     "not included",
+                "tests/a.zig":   "not included",
+        })
+        if err := os.Symlink("main.zig", filepath.Join(dir, "src", "link.zig")); err != nil {
+                t.Skipf("symlinks are not supported: %v", err)
+        }
+        if err := os.Chmod(filepath.Join(dir, "src", "main.zig"), 0755); err != nil {
+                t.Fatal(err)
+        }
+
+        hash, err = Calculate(ZigHash, dir)
+        if err != nil {
+                t.Fatalf("Calculate: %v", err)
+        }
+        if want := "zig-1220ba45e60dc048335aa036fca84f2424f97f720ef17d6e4fcda2ec79301578da2a"; hash != want {
+                t.Errorf("Calculate(ZigHash) = %s, want %s", hash, want)
+        }
+}
+
+func TestZonPaths(t *testing.T) {
+        tests := []struct {
+                src  string
+                want []string
+        }{
+                {testZon, []string{"build.zig", "build.zig.zon", "src"}},
+                {`.{ .name = "x", .paths = .{""} }`, []string{""}},
+                {`.{ .paths = .{ "./src/", "a\"b" } }`, []string{"src", `a"b`}},
+                {`.{ .name = ".paths" }`, nil},
+                {`.{ .name = "x" }`, nil},
+        }
+        for _, tt := range tests {
+                got, err := parseZonPaths(tt.src)
+                if err != nil {
+                        t.Errorf("parseZonPaths(%q): %v", tt.src, err)
+                        continue
+                }
+                if !reflect.DeepEqual(got, tt.want) {
+                        t.Errorf("parseZonPaths(%q) = %q, want %q", tt.src, got, tt.want)
+                }
+        }
+
+        for _, src := range []string{`.{ .paths = "src" }`, `.{ .paths = .{ "src" `, `.{ .paths = .{ src } }`} {
+                if _, err := parseZonPaths(src); err == nil {
+                        t.Errorf("parseZonPaths(%q) succeeded, want an error", src)
+                }
+        }
+
+        paths, err := ZonPaths(t.TempDir())
+        if err != nil || paths != nil {
+                t.Errorf("ZonPaths without a build.zig.zon = %q, %v", paths, err)
+        }
+}
+
+func TestZigIncludes(t *testing.T) {
+        include := map[string]bool{"src": true, "build.zig": true}
+        tests := map[string]bool{
+                "build.zig":       true,
+                "src/main.zig":    true,
+                "src/a/b.zig":     true,
+                "srcs/main.zig":   false,
+                "build.zig.zon":   false,
+                "tests/build.zig": false,
+        }
+        for file, want := range tests {
+                if got := zigIncludes(include, file); got != want {
+                        t.Errorf("zigIncludes(%q) = %t, want %t", file, got, want)
+                }
+        }
+        if !zigIncludes(nil, "anything") || !zigIncludes(map[string]bool{"": true}, "a/b") {
+                t.Errorf("an empty or root .paths should include every file")
+        }
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/fatih/color"
 )
@@ -12,6 +13,10 @@ var (
 	quiet   = false
 )
 
+// mu keeps the colored prefix and the message of one line together when
+// several goroutines log at once.
+var mu sync.Mutex
+
 
 var (
 	infoColor    = color.New(color.FgCyan, color.Bold)
@@ -33,6 +38,8 @@ func Info(format string, args ...any) {
 	if quiet {
 		return
 	}
+	mu.Lock()
+	defer mu.Unlock()
 	infoColor.Print("[INFO] ")
 	fmt.Printf(format+"\n", args...)
 }
@@ -41,11 +48,15 @@ func Warn(format string, args ...any) {
 	if quiet {
 		return
 	}
+	mu.Lock()
+	defer mu.Unlock()
 	warnColor.Print("[WARN] ")
 	fmt.Printf(format+"\n", args...)
 }
 
 func Error(format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
 	errorColor.Print("[ERROR] ")
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 }
@@ -54,6 +65,8 @@ func Success(format string, args ...any) {
 	if quiet {
 		return
 	}
+	mu.Lock()
+	defer mu.Unlock()
 	successColor.Print("[SUCCESS] ")
 	fmt.Printf(format+"\n", args...)
 }
@@ -62,6 +75,8 @@ func Debug(format string, args ...any) {
 	if !verbose || quiet {
 		return
 	}
+	mu.Lock()
+	defer mu.Unlock()
 	debugColor.Print("[DEBUG] ")
 	fmt.Printf(format+"\n", args...)
 }
@@ -11,6 +11,7 @@ import (
 var (
 	verbose bool
 	quiet   bool
+	pkg     string
 )
 
 func main() {
@@ -21,11 +22,13 @@ func main() {
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			logger.SetVerbose(verbose)
 			logger.SetQuiet(quiet)
+			cli.SetPackage(pkg)
 		},
 	}
 
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Enable quiet mode")
+	rootCmd.PersistentFlags().StringVarP(&pkg, "package", "p", "", "Workspace member to operate on")
 
 	rootCmd.AddCommand(cli.InitCmd())
 	rootCmd.AddCommand(cli.BuildCmd())
@@ -35,8 +38,10 @@ func main() {
 	rootCmd.AddCommand(cli.AddCmd())
 	rootCmd.AddCommand(cli.InstallCmd())
 	rootCmd.AddCommand(cli.UpdateCmd())
+	rootCmd.AddCommand(cli.LockCmd())
 	rootCmd.AddCommand(cli.RemoveCmd())
 	rootCmd.AddCommand(cli.SyncCmd())
+	rootCmd.AddCommand(cli.VerifyCmd())
 	rootCmd.AddCommand(cli.OutdatedCmd())
 	rootCmd.AddCommand(cli.ListCmd())
 	rootCmd.AddCommand(cli.WhyCmd())
@@ -45,6 +50,11 @@ func main() {
 	rootCmd.AddCommand(cli.DoctorCmd())
 	rootCmd.AddCommand(cli.CacheCmd())
 	rootCmd.AddCommand(cli.ConfigCmd())
+	rootCmd.AddCommand(cli.ScriptCmd())
+
+	if args := cli.ScriptAlias(rootCmd, os.Args[1:]); args != nil {
+		rootCmd.SetArgs(args)
+	}
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)	
@@ -0,0 +1,128 @@
+package manifest
+
+import (
+        "fmt"
+        "sort"
+        "strings"
+)
+
+// DefaultFeature is the feature enabled unless --no-default-features is given.
+const DefaultFeature = "default"
+
+// FeatureSet is the result of enabling features of a manifest: the features
+// that are on, following the features they enable in turn, and the optional
+// dependencies they turn on.
+type FeatureSet struct {
+        Features map[string]bool
+        Deps     map[string]bool
+}
+
+// Enabled reports whether a feature or optional dependency is turned on.
+func (fs *FeatureSet) Enabled(name string) bool {
+        return fs.Features[name] || fs.Deps[name]
+}
+
+// FeatureNames returns every feature the manifest declares, including the
+// implicit feature named after each optional dependency, sorted.
+func (m *Manifest) FeatureNames() []string {
+        seen := make(map[string]bool)
+        var names []string
+        for name := range m.Features {
+                seen[name] = true
+                names = append(names, name)
+        }
+        for name, dep := range m.GetAllDependencies() {
+                if dep.Optional && !seen[name] {
+                        names = append(names, name)
+                }
+        }
+        sort.Strings(names)
+        return names
+}
+
+// ResolveFeatures enables the requested features, plus the default feature
+// if withDefault is set. An entry of a feature is either another feature or
+// an optional dependency, written as "name" or "dep:name".
+func (m *Manifest) ResolveFeatures(requested []string, withDefault bool) (*FeatureSet, error) {
+        fs := &FeatureSet{
+                Features: make(map[string]bool),
+                Deps:     make(map[string]bool),
+        }
+        allDeps := m.GetAllDependencies()
+
+        var enable func(name, from string) error
+        enable = func(name, from string) error {
+                if depName, isDep := strings.CutPrefix(name, "dep:"); isDep {
+                        if dep, exists := allDeps[depName]; !exists || !dep.Optional {
+                                return fmt.Errorf("feature '%s' enables '%s', which is not an optional dependency", from, depName)
+                        }
+                        fs.Deps[depName] = true
+                        return nil
+                }
+
+                if fs.Features[name] {
+                        return nil
+                }
+
+                if entries, exists := m.Features[name]; exists {
+                        fs.Features[name] = true
+                        for _, entry := range entries {
+                                if err := enable(entry, name); err != nil {
+                                        return err
+                                }
+                        }
+                        return nil
+                }
+
+                if dep, exists := allDeps[name]; exists && dep.Optional {
+                        fs.Features[name] = true
+                        fs.Deps[name] = true
+                        return nil
+                }
+
+                if from == "" {
+                        return fmt.Errorf("package '%s' has no feature named '%s'", m.Package.Name, name)
+                }
+                return fmt.Errorf("feature '%s' enables unknown feature '%s'", from, name)
+        }
+
+        if withDefault {
+                if _, exists := m.Features[DefaultFeature]; exists {
+                        if err := enable(DefaultFeature, ""); err != nil {
+                                return nil, err
+                        }
+                }
+        }
+
+        for _, name := range requested {
+                if name = strings.TrimSpace(name); name == "" {
+                        continue
+                }
+                if err := enable(name, ""); err != nil {
+                        return nil, err
+                }
+        }
+
+        return fs, nil
+}
+
+// EnabledDependencies returns the dependencies of the manifest without the
+// optional ones fs does not turn on.
+func (m *Manifest) EnabledDependencies(fs *FeatureSet) map[string]Dependency {
+        enabled := make(map[string]Dependency)
+        for name, dep := range m.GetAllDependencies() {
+                if !dep.Optional || fs.Deps[name] {
+                        enabled[name] = dep
+                }
+        }
+        return enabled
+}
+
+func validateFeatures(m *Manifest) error {
+        for name := range m.Features {
+                if _, err := m.ResolveFeatures([]string{name}, false); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
@@ -0,0 +1,136 @@
+package manifest
+
+import (
+        "bytes"
+        "crypto/sha256"
+        "fmt"
+        "regexp"
+        "strings"
+
+        "github.com/BurntSushi/toml"
+        "yuki_zpm.org/integrity"
+)
+
+// LockFileVersion is the version of the yuki.lock format written by this
+// version of yuki. Older lock files are migrated when they are loaded.
+const LockFileVersion = "2"
+
+// Ways a locked version can have been chosen.
+const (
+        RefVersion = "version"
+        RefTag     = "tag"
+        RefBranch  = "branch"
+        RefRev     = "rev"
+        RefPath    = "path"
+)
+
+// Ways the project can use a locked package. A package that is reached in
+// several ways gets the first of normal, build and dev.
+const (
+        KindNormal = "normal"
+        KindDev    = "dev"
+        KindBuild  = "build"
+)
+
+var (
+        commitPattern  = regexp.MustCompile(`^[0-9a-f]{40}$`)
+        versionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+`)
+)
+
+// migrate upgrades a lock file read from disk to LockFileVersion. Details
+// version 1 did not record, such as constraints and kinds, stay empty until
+// the lock file is next resolved.
+func (l *LockFile) migrate() error {
+        switch l.Metadata.Version {
+        case LockFileVersion:
+                return nil
+        case "", "1":
+        default:
+                return fmt.Errorf("yuki.lock has version %s, which this version of yuki does not support", l.Metadata.Version)
+        }
+
+        for i := range l.Package {
+                pkg := &l.Package[i]
+                if pkg.Integrity == "" && pkg.Checksum != "" {
+                        pkg.Integrity = integrity.Format(integrity.SHA256, pkg.Checksum)
+                }
+                pkg.Checksum = ""
+                if pkg.Ref == "" {
+                        pkg.Ref = inferRef(*pkg)
+                }
+        }
+
+        l.Metadata.Version = LockFileVersion
+        return nil
+}
+
+// inferRef guesses the ref kind of a version 1 entry from its version.
+func inferRef(pkg LockedPackage) string {
+        switch {
+        case strings.HasPrefix(pkg.Source, SourcePath+"+"):
+                return RefPath
+        case commitPattern.MatchString(pkg.Version):
+                return RefRev
+        case versionPattern.MatchString(pkg.Version) && strings.HasPrefix(pkg.Version, "v"):
+                // Versions chosen from a range are recorded without the
+                // "v" their tag may have; a tag requirement keeps it.
+                return RefTag
+        case versionPattern.MatchString(pkg.Version):
+                return RefVersion
+        default:
+                return RefBranch
+        }
+}
+
+// describeLockDetails describes a change of the ref kind, constraint or kind
+// of a package. Details the old entry does not know, e.g. because it was
+// migrated from version 1, do not count as changes.
+func describeLockDetails(old, new LockedPackage) string {
+        switch {
+        case old.Ref != "" && old.Ref != new.Ref:
+                return fmt.Sprintf("ref %s -> %s", old.Ref, new.Ref)
+        case old.Constraint != "" && old.Constraint != new.Constraint:
+                return fmt.Sprintf("constraint %s -> %s", old.Constraint, new.Constraint)
+        case old.Kind != "" && old.Kind != new.Kind:
+                return fmt.Sprintf("kind %s -> %s", old.Kind, new.Kind)
+        default:
+                return ""
+        }
+}
+
+// dependencyTables are the parts of a manifest that yuki.lock depends on.
+type dependencyTables struct {
+        Dependencies map[string]Dependency           `toml:"dependencies,omitempty"`
+        DevDeps      map[string]Dependency           `toml:"dev-dependencies,omitempty"`
+        BuildDeps    map[string]Dependency           `toml:"build-dependencies,omitempty"`
+        Target       map[string]TargetDependencies `toml:"target,omitempty"`
+}
+
+// Fingerprint hashes the dependency tables of the manifests sharing a lock
+// file, keyed by package name, and the [patch] table that applies to them.
+// Other edits to yuki.toml, such as a new description or script, do not
+// change it.
+func Fingerprint(manifests map[string]*Manifest, patch map[string]Dependency) string {
+        input := struct {
+                Packages map[string]dependencyTables `toml:"packages"`
+                Patch    map[string]Dependency       `toml:"patch,omitempty"`
+        }{
+                Packages: make(map[string]dependencyTables),
+                Patch:    patch,
+        }
+        for name, m := range manifests {
+                input.Packages[name] = dependencyTables{
+                        Dependencies: m.Dependencies,
+                        DevDeps:      m.DevDeps,
+                        BuildDeps:    m.BuildDeps,
+                        Target:       m.Target,
+                }
+        }
+
+        // The encoder sorts map keys, so equal tables always hash the same.
+        var buf bytes.Buffer
+        if err := toml.NewEncoder(&buf).Encode(input); err != nil {
+                return ""
+        }
+        return integrity.Format(integrity.SHA256, fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())))
+}
@@ -0,0 +1,161 @@
+package manifest
+
+import (
+        "os"
+        "path/filepath"
+        "strings"
+        "testing"
+)
+
+// v1Lock is a yuki.lock as version 1 wrote it, with bare checksums and no
+// ref kinds.
+const v1Lock = `[metadata]
+  version = "1"
+
+[[package]]
+  name = "ranged"
+  version = "1.2.0"
+  source = "owner/ranged"
+  commit = "0123456789abcdef0123456789abcdef01234567"
+  checksum = "aaaa"
+  dependencies = ["tagged"]
+
+[[package]]
+  name = "tagged"
+  version = "v2.0.0"
+  source = "owner/tagged"
+  checksum = "bbbb"
+
+[[package]]
+  name = "pinned"
+  version = "0123456789abcdef0123456789abcdef01234567"
+  source = "git+https://example.com/pinned.git"
+
+[[package]]
+  name = "branch"
+  version = "main"
+  source = "owner/branch"
+
+[[package]]
+  name = "local"
+  version = "0.1.0"
+  source = "path+../local"
+`
+
+func writeLock(t *testing.T, content string) string {
+        t.Helper()
+        dir := t.TempDir()
+        if err := os.WriteFile(filepath.Join(dir, LockFileName), []byte(content), 0644); err != nil {
+                t.Fatal(err)
+        }
+        return dir
+}
+
+func TestLoadLockFileMigratesVersion1(t *testing.T) {
+        dir := writeLock(t, v1Lock)
+        lockFile, err := LoadLockFile(dir)
+        if err != nil {
+                t.Fatalf("LoadLockFile: %v", err)
+        }
+        if lockFile.Metadata.Version != LockFileVersion {
+                t.Errorf("version = %q, want %q", lockFile.Metadata.Version, LockFileVersion)
+        }
+
+        tests := []struct {
+                name      string
+                ref       string
+                integrity string
+        }{
+                {"ranged", RefVersion, "sha256-aaaa"},
+                {"tagged", RefTag, "sha256-bbbb"},
+                {"pinned", RefRev, ""},
+                {"branch", RefBranch, ""},
+                {"local", RefPath, ""},
+        }
+        for _, tt := range tests {
+                pkg, found := lockFile.Find(tt.name)
+                if !found {
+                        t.Errorf("%s is missing", tt.name)
+                        continue
+                }
+                if pkg.Ref != tt.ref || pkg.Integrity != tt.integrity || pkg.Checksum != "" {
+                        t.Errorf("%s migrated to ref %q, integrity %q, checksum %q; want ref %q, integrity %q",
+                                tt.name, pkg.Ref, pkg.Integrity, pkg.Checksum, tt.ref, tt.integrity)
+                }
+        }
+        if pkg, _ := lockFile.Find("ranged"); len(pkg.Deps) != 1 || pkg.Commit == "" {
+                t.Errorf("migration lost details of ranged: %+v", pkg)
+        }
+
+        // Saved again, the lock file is a version 2 file that loads the same.
+        if err := lockFile.Save(dir); err != nil {
+                t.Fatalf("Save: %v", err)
+        }
+        data, err := os.ReadFile(filepath.Join(dir, LockFileName))
+        if err != nil {
+                t.Fatal(err)
+        }
+        if !strings.Contains(string(data), `version = "2"`) || strings.Contains(string(data), "checksum =") {
+                t.Errorf("unexpected saved lock file:\n%s", data)
+        }
+        reloaded, err := LoadLockFile(dir)
+        if err != nil {
+                t.Fatalf("LoadLockFile: %v", err)
+        }
+        if changes := DiffLockFiles(lockFile, reloaded); len(changes) > 0 {
+                t.Errorf("reloading changed the lock file: %v", changes)
+        }
+}
+
+func TestLoadLockFileVersions(t *testing.T) {
+        // A lock file without a version predates the version field.
+        lockFile, err := LoadLockFile(writeLock(t, "[[package]]\n  name = \"a\"\n  version = \"1.0.0\"\n  source = \"owner/a\"\n  checksum = \"cc\"\n"))
+        if err != nil {
+                t.Fatalf("LoadLockFile: %v", err)
+        }
+        if pkg, _ := lockFile.Find("a"); pkg.Integrity != "sha256-cc" || lockFile.Metadata.Version != LockFileVersion {
+                t.Errorf("unversioned lock file was not migrated: %+v", lockFile)
+        }
+
+        // Version 2 entries are taken as they are.
+        lockFile, err = LoadLockFile(writeLock(t, "[metadata]\n  version = \"2\"\n\n[[package]]\n  name = \"a\"\n  version = \"v1.0.0\"\n  source = \"owner/a\"\n  ref = \"version\"\n  integrity = \"zig-1220aa\"\n"))
+        if err != nil {
+                t.Fatalf("LoadLockFile: %v", err)
+        }
+        if pkg, _ := lockFile.Find("a"); pkg.Ref != RefVersion || pkg.Integrity != "zig-1220aa" {
+                t.Errorf("version 2 entry was changed: %+v", pkg)
+        }
+
+        if _, err := LoadLockFile(writeLock(t, "[metadata]\n  version = \"3\"\n")); err == nil || !strings.Contains(err.Error(), "version 3") {
+                t.Errorf("got %v, want an error about version 3", err)
+        }
+
+        // A missing lock file is an empty current one.
+        lockFile, err = LoadLockFile(t.TempDir())
+        if err != nil || len(lockFile.Package) != 0 || lockFile.Metadata.Version != LockFileVersion {
+                t.Errorf("LoadLockFile without yuki.lock = %+v, %v", lockFile, err)
+        }
+}
+
+func TestDiffLockFilesIgnoresMigratedDetails(t *testing.T) {
+        old, err := LoadLockFile(writeLock(t, v1Lock))
+        if err != nil {
+                t.Fatalf("LoadLockFile: %v", err)
+        }
+
+        resolved := &LockFile{Metadata: old.Metadata}
+        for _, pkg := range old.Package {
+                pkg.Constraint = "^1.0"
+                pkg.Kind = KindNormal
+                resolved.Package = append(resolved.Package, pkg)
+        }
+        if changes := DiffLockFiles(old, resolved); len(changes) > 0 {
+                t.Errorf("details version 1 did not record were reported: %v", changes)
+        }
+
+        resolved.Package[0].Ref = RefTag
+        changes := DiffLockFiles(old, resolved)
+        if len(changes) != 1 || changes[0] != "ranged ref version -> tag" {
+                t.Errorf("DiffLockFiles = %v, want the ref change of ranged", changes)
+        }
+}
@@ -2,8 +2,11 @@ package manifest
 
 import (
         "fmt"
+        "net/url"
         "os"
         "path/filepath"
+        "sort"
+        "strings"
 
         "github.com/BurntSushi/toml"
 )
@@ -13,8 +16,19 @@ type Manifest struct {
         Dependencies map[string]Dependency  `toml:"dependencies,omitempty"`
         DevDeps      map[string]Dependency  `toml:"dev-dependencies,omitempty"`
         BuildDeps    map[string]Dependency  `toml:"build-dependencies,omitempty"`
+        Target       map[string]TargetDependencies `toml:"target,omitempty"`
         Features     map[string][]string    `toml:"features,omitempty"`
         Scripts      map[string]string      `toml:"scripts,omitempty"`
+        Workspace    *Workspace             `toml:"workspace,omitempty"`
+        Patch        map[string]Dependency  `toml:"patch,omitempty"`
+}
+
+// Workspace lists the member packages of a workspace. Members are
+// directories relative to the workspace root and may use glob patterns such
+// as "libs/*".
+type Workspace struct {
+        Members []string `toml:"members"`
+        Exclude []string `toml:"exclude,omitempty"`
 }
 
 type PackageInfo struct {
@@ -31,13 +45,60 @@ type PackageInfo struct {
 }
 
 type Dependency struct {
-        Git               string `toml:"git"`
+        Git               string `toml:"git,omitempty"`
+        Archive           string `toml:"archive,omitempty"`
+        Path              string `toml:"path,omitempty"`
         Version           string `toml:"version"`
         Branch            string `toml:"branch,omitempty"`
         Tag               string `toml:"tag,omitempty"`
         Rev               string `toml:"rev,omitempty"`
         RootFile           string `toml:"root_file,omitempty"`
+        Prerelease      bool   `toml:"prerelease,omitempty"`
+        // Optional dependencies are only used when a feature turns them on.
+        Optional        bool   `toml:"optional,omitempty"`
         UseLatestCommit bool   `toml:"-"`
+        // BaseDir is the directory a relative Path is resolved against. It
+        // is set by the resolver and defaults to the working directory.
+        BaseDir         string `toml:"-"`
+}
+
+// Kinds of sources a dependency can be fetched from.
+const (
+        SourceGitHub  = "github"
+        SourceGit     = "git"
+        SourceArchive = "archive"
+        SourcePath    = "path"
+)
+
+// SourceKind reports where the dependency is fetched from: a local
+// directory, a tarball or zip archive, a repository on GitHub or any other
+// git repository.
+func (d Dependency) SourceKind() string {
+        switch {
+        case d.Path != "":
+                return SourcePath
+        case d.Archive != "":
+                return SourceArchive
+        case isGitHubURL(d.Git):
+                return SourceGitHub
+        default:
+                return SourceGit
+        }
+}
+
+// isGitHubURL accepts the "owner/repo" shorthand as well as HTTPS and SSH
+// URLs on github.com.
+func isGitHubURL(repoURL string) bool {
+        if strings.HasPrefix(repoURL, "git@github.com:") {
+                return true
+        }
+        if !strings.Contains(repoURL, "://") && !strings.Contains(repoURL, "@") {
+                return strings.Count(repoURL, "/") == 1 && !strings.HasPrefix(repoURL, ".") && !strings.HasPrefix(repoURL, "/")
+        }
+        if u, err := url.Parse(repoURL); err == nil {
+                return u.Host == "github.com" || u.Host == "www.github.com"
+        }
+        return false
 }
 
 type LockFile struct {
@@ -47,14 +108,38 @@ type LockFile struct {
 
 type LockMetadata struct {
         Version string `toml:"version"`
+        // Fingerprint is the Fingerprint of the manifests the lock file was
+        // resolved from.
+        Fingerprint string `toml:"fingerprint,omitempty"`
 }
 
 type LockedPackage struct {
-        Name     string `toml:"name"`
-        Version  string `toml:"version"`
-        Source   string `toml:"source"`
-        Checksum string `toml:"checksum"`
-        Deps     []string `toml:"dependencies,omitempty"`
+        Name       string   `toml:"name"`
+        Version    string   `toml:"version"`
+        Source     string   `toml:"source"`
+        // Ref is how Version was chosen: RefVersion, RefTag, RefBranch,
+        // RefRev or RefPath.
+        Ref        string   `toml:"ref,omitempty"`
+        // Constraint lists the requirements dependents place on the
+        // package, comma separated, as written in their manifests.
+        Constraint string   `toml:"constraint,omitempty"`
+        Commit     string   `toml:"commit,omitempty"`
+        // Integrity is the hash of the package contents prefixed with the
+        // scheme it was calculated with: "zig-1220<hex>" for Zig's package
+        // hash, or "sha256-<hex>" for the original directory checksum.
+        // Neither covers file modes: Zig hashes every file as not
+        // executable, and a hash that included modes would no longer match
+        // `zig fetch`.
+        Integrity  string   `toml:"integrity,omitempty"`
+        // Checksum is the bare SHA-256 checksum of version 1 lock files.
+        // LoadLockFile moves it to Integrity.
+        Checksum   string   `toml:"checksum,omitempty"`
+        // Kind is how the project uses the package: KindNormal, KindDev or
+        // KindBuild.
+        Kind       string   `toml:"kind,omitempty"`
+        Deps       []string `toml:"dependencies,omitempty"`
+        // Patched is the source a [patch] entry replaced with Source.
+        Patched    string   `toml:"patched,omitempty"`
 }
 
 const ManifestFile = "yuki.toml"
@@ -103,7 +188,7 @@ func LoadLockFile(path string) (*LockFile, error) {
         if err != nil {
                 if os.IsNotExist(err) {
                         return &LockFile{
-                                Metadata: LockMetadata{Version: "1"},
+                                Metadata: LockMetadata{Version: LockFileVersion},
                                 Package:  []LockedPackage{},
                         }, nil
                 }
@@ -115,6 +200,10 @@ func LoadLockFile(path string) (*LockFile, error) {
                 return nil, fmt.Errorf("failed to parse lock file: %w", err)
         }
 
+        if err := lockFile.migrate(); err != nil {
+                return nil, err
+        }
+
         return &lockFile, nil
 }
 
@@ -137,6 +226,151 @@ func (l *LockFile) Save(path string) error {
 }
 
 
+// Find returns the locked entry for a package name.
+func (l *LockFile) Find(name string) (LockedPackage, bool) {
+        for _, pkg := range l.Package {
+                if pkg.Name == name {
+                        return pkg, true
+                }
+        }
+        return LockedPackage{}, false
+}
+
+
+// DiffLockFiles describes every package that was added, removed or changed
+// between two lock files. An empty result means both lock the same graph
+// for the same manifests.
+func DiffLockFiles(old, new *LockFile) []string {
+        var changes []string
+
+        for _, pkg := range new.Package {
+                prev, exists := old.Find(pkg.Name)
+                if !exists {
+                        changes = append(changes, fmt.Sprintf("added %s %s", pkg.Name, pkg.Version))
+                        continue
+                }
+                if prev.Version != pkg.Version {
+                        changes = append(changes, fmt.Sprintf("%s %s -> %s", pkg.Name, prev.Version, pkg.Version))
+                } else if prev.Source != pkg.Source {
+                        changes = append(changes, fmt.Sprintf("%s source %s -> %s", pkg.Name, prev.Source, pkg.Source))
+                } else if prev.Commit != pkg.Commit {
+                        changes = append(changes, fmt.Sprintf("%s commit %s -> %s", pkg.Name, shortCommit(prev.Commit), shortCommit(pkg.Commit)))
+                } else if prev.Integrity != pkg.Integrity {
+                        changes = append(changes, fmt.Sprintf("%s checksum changed", pkg.Name))
+                } else if strings.Join(prev.Deps, ",") != strings.Join(pkg.Deps, ",") {
+                        changes = append(changes, fmt.Sprintf("%s dependencies changed", pkg.Name))
+                } else if prev.Patched != pkg.Patched {
+                        changes = append(changes, fmt.Sprintf("%s patch changed", pkg.Name))
+                } else if change := describeLockDetails(prev, pkg); change != "" {
+                        changes = append(changes, fmt.Sprintf("%s %s", pkg.Name, change))
+                }
+        }
+
+        for _, pkg := range old.Package {
+                if _, exists := new.Find(pkg.Name); !exists {
+                        changes = append(changes, fmt.Sprintf("removed %s %s", pkg.Name, pkg.Version))
+                }
+        }
+
+        if old.Metadata.Fingerprint != "" && old.Metadata.Fingerprint != new.Metadata.Fingerprint {
+                changes = append(changes, "dependencies in yuki.toml changed")
+        }
+
+        return changes
+}
+
+func shortCommit(commit string) string {
+        if commit == "" {
+                return "(none)"
+        }
+        if len(commit) > 8 {
+                return commit[:8]
+        }
+        return commit
+}
+
+
+// PruneUnreachable drops every locked package that can no longer be reached
+// from the given root dependency names and returns the names it removed.
+func (l *LockFile) PruneUnreachable(roots []string) []string {
+        byName := make(map[string]LockedPackage)
+        for _, pkg := range l.Package {
+                byName[pkg.Name] = pkg
+        }
+
+        reachable := make(map[string]bool)
+        queue := append([]string{}, roots...)
+        for len(queue) > 0 {
+                name := queue[0]
+                queue = queue[1:]
+                if reachable[name] {
+                        continue
+                }
+                pkg, exists := byName[name]
+                if !exists {
+                        continue
+                }
+                reachable[name] = true
+                queue = append(queue, pkg.Deps...)
+        }
+
+        var kept []LockedPackage
+        var removed []string
+        for _, pkg := range l.Package {
+                if reachable[pkg.Name] {
+                        kept = append(kept, pkg)
+                } else {
+                        removed = append(removed, pkg.Name)
+                }
+        }
+        l.Package = kept
+
+        return removed
+}
+
+// Subset returns a copy of the lock file that only holds the packages
+// reachable from roots.
+func (l *LockFile) Subset(roots []string) *LockFile {
+        subset := &LockFile{
+                Metadata: l.Metadata,
+                Package:  append([]LockedPackage{}, l.Package...),
+        }
+        subset.PruneUnreachable(roots)
+        return subset
+}
+
+// Without returns a copy of the lock file that leaves out the named packages.
+func (l *LockFile) Without(names []string) *LockFile {
+        excluded := make(map[string]bool, len(names))
+        for _, name := range names {
+                excluded[name] = true
+        }
+
+        without := &LockFile{Metadata: l.Metadata, Package: []LockedPackage{}}
+        for _, pkg := range l.Package {
+                if !excluded[pkg.Name] {
+                        without.Package = append(without.Package, pkg)
+                }
+        }
+        return without
+}
+
+// DependencyNames returns the sorted names of every dependency of the
+// manifest, including dev and build dependencies.
+func (m *Manifest) DependencyNames() []string {
+        return SortedDependencyNames(m.GetAllDependencies())
+}
+
+// SortedDependencyNames returns the names of deps in sorted order.
+func SortedDependencyNames(deps map[string]Dependency) []string {
+        names := make([]string, 0, len(deps))
+        for name := range deps {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        return names
+}
+
 func (m *Manifest) Validate() error {
         if m.Package.Name == "" {
                 return fmt.Errorf("package name is required")
@@ -155,12 +389,60 @@ func (m *Manifest) Validate() error {
                 }
         }
 
+        for _, spec := range m.TargetNames() {
+                if _, err := ParseTarget(spec); err != nil {
+                        return err
+                }
+                for name, dep := range m.Target[spec].all() {
+                        if err := validateDependency(name, dep); err != nil {
+                                return err
+                        }
+                }
+        }
+
+        if err := validateFeatures(m); err != nil {
+                return err
+        }
+
+        for location, dep := range m.Patch {
+                if err := validatePatch(location, dep); err != nil {
+                        return err
+                }
+        }
+
+        return nil
+}
+
+// validatePatch checks a [patch] entry. Unlike a dependency it needs no
+// version, since the requirements of the dependents still apply.
+func validatePatch(location string, dep Dependency) error {
+        sources := 0
+        for _, source := range []string{dep.Git, dep.Archive, dep.Path} {
+                if source != "" {
+                        sources++
+                }
+        }
+        if sources != 1 {
+                return fmt.Errorf("patch for '%s' must have exactly one of git, archive and path", location)
+        }
         return nil
 }
 
 func validateDependency(name string, dep Dependency) error {
-        if dep.Git == "" {
-                return fmt.Errorf("dependency '%s' must have a git URL", name)
+        sources := 0
+        for _, location := range []string{dep.Git, dep.Archive, dep.Path} {
+                if location != "" {
+                        sources++
+                }
+        }
+        if sources == 0 {
+                return fmt.Errorf("dependency '%s' must have a git URL, an archive URL or a path", name)
+        }
+        if sources > 1 {
+                return fmt.Errorf("dependency '%s' must have only one of git, archive and path", name)
+        }
+        if dep.Archive != "" || dep.Path != "" {
+                return nil
         }
         
         
@@ -175,6 +457,12 @@ func validateDependency(name string, dep Dependency) error {
 
 func (m *Manifest) GetAllDependencies() map[string]Dependency {
         all := make(map[string]Dependency)
+
+        for _, spec := range m.TargetNames() {
+                for name, dep := range m.Target[spec].all() {
+                        all[name] = dep
+                }
+        }
         
         for name, dep := range m.Dependencies {
                 all[name] = dep
@@ -0,0 +1,146 @@
+package manifest
+
+import (
+        "fmt"
+        "regexp"
+        "sort"
+        "strings"
+)
+
+// TargetDependencies are the dependency tables of a [target.'<spec>'] table,
+// which only apply when building for a matching target.
+type TargetDependencies struct {
+        Dependencies map[string]Dependency `toml:"dependencies,omitempty"`
+        DevDeps      map[string]Dependency `toml:"dev-dependencies,omitempty"`
+        BuildDeps    map[string]Dependency `toml:"build-dependencies,omitempty"`
+}
+
+func (t TargetDependencies) all() map[string]Dependency {
+        all := make(map[string]Dependency)
+        for _, deps := range []map[string]Dependency{t.Dependencies, t.DevDeps, t.BuildDeps} {
+                for name, dep := range deps {
+                        all[name] = dep
+                }
+        }
+        return all
+}
+
+// TargetSpec is a parsed target table key such as "os=linux" or
+// "os=linux,arch=x86_64". Empty fields match any target.
+type TargetSpec struct {
+        OS   string
+        Arch string
+}
+
+var targetValueRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// ParseTarget parses the key of a [target] table. Values are Zig's names for
+// std.Target.Os.Tag and std.Target.Cpu.Arch.
+func ParseTarget(spec string) (TargetSpec, error) {
+        var target TargetSpec
+        for _, part := range strings.Split(spec, ",") {
+                key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+                key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+                if !found || !targetValueRegex.MatchString(value) {
+                        return TargetSpec{}, fmt.Errorf("invalid target '%s': expected entries like os=linux or arch=x86_64", spec)
+                }
+
+                switch key {
+                case "os":
+                        target.OS = value
+                case "arch":
+                        target.Arch = value
+                default:
+                        return TargetSpec{}, fmt.Errorf("invalid target '%s': unknown key '%s', expected os or arch", spec, key)
+                }
+        }
+        return target, nil
+}
+
+func (t TargetSpec) String() string {
+        var parts []string
+        if t.OS != "" {
+                parts = append(parts, "os="+t.OS)
+        }
+        if t.Arch != "" {
+                parts = append(parts, "arch="+t.Arch)
+        }
+        return strings.Join(parts, ",")
+}
+
+// TargetNames returns the keys of the manifest's [target] tables, sorted.
+func (m *Manifest) TargetNames() []string {
+        names := make([]string, 0, len(m.Target))
+        for name := range m.Target {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        return names
+}
+
+// DependencyTargets returns the targets a dependency is limited to, or nil
+// if it applies to every target because it is declared outside of a
+// [target] table.
+func (m *Manifest) DependencyTargets(name string) []TargetSpec {
+        for _, deps := range []map[string]Dependency{m.Dependencies, m.DevDeps, m.BuildDeps} {
+                if _, exists := deps[name]; exists {
+                        return nil
+                }
+        }
+
+        var targets []TargetSpec
+        for _, spec := range m.TargetNames() {
+                if _, exists := m.Target[spec].all()[name]; !exists {
+                        continue
+                }
+                if target, err := ParseTarget(spec); err == nil {
+                        targets = append(targets, target)
+                }
+        }
+        return targets
+}
+
+// RuntimeDependencies returns the dependencies a package needs at runtime,
+// including those of every [target] table.
+func (m *Manifest) RuntimeDependencies() map[string]Dependency {
+        deps := make(map[string]Dependency)
+        for _, spec := range m.TargetNames() {
+                for name, dep := range m.Target[spec].Dependencies {
+                        deps[name] = dep
+                }
+        }
+        for name, dep := range m.Dependencies {
+                deps[name] = dep
+        }
+        return deps
+}
+
+// DevDependencies returns the dev dependencies, including those of every
+// [target] table.
+func (m *Manifest) DevDependencies() map[string]Dependency {
+        deps := make(map[string]Dependency)
+        for _, spec := range m.TargetNames() {
+                for name, dep := range m.Target[spec].DevDeps {
+                        deps[name] = dep
+                }
+        }
+        for name, dep := range m.DevDeps {
+                deps[name] = dep
+        }
+        return deps
+}
+
+// BuildDependencies returns the build dependencies, including those of
+// every [target] table.
+func (m *Manifest) BuildDependencies() map[string]Dependency {
+        deps := make(map[string]Dependency)
+        for _, spec := range m.TargetNames() {
+                for name, dep := range m.Target[spec].BuildDeps {
+                        deps[name] = dep
+                }
+        }
+        for name, dep := range m.BuildDeps {
+                deps[name] = dep
+        }
+        return deps
+}
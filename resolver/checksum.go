@@ -0,0 +1,93 @@
+package resolver
+
+import (
+	"fmt"
+
+	"yuki_zpm.org/fetch"
+	"yuki_zpm.org/integrity"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/source"
+)
+
+// ChecksumError is returned when a package fetched at a version yuki.lock
+// records no longer has the contents the lock file trusted on first use.
+type ChecksumError struct {
+	Name    string
+	Version string
+	// Commits are empty for sources without commits, such as archives.
+	LockedCommit  string
+	FetchedCommit string
+	// Expected and Actual are integrity strings of the same algorithm.
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	var msg string
+	if e.LockedCommit != "" && e.FetchedCommit != "" && e.LockedCommit != e.FetchedCommit {
+		msg = fmt.Sprintf("the tag of '%s' %s moved: yuki.lock has commit %s, but it now points to %s", e.Name, e.Version, shortSHA(e.LockedCommit), shortSHA(e.FetchedCommit))
+	} else {
+		msg = fmt.Sprintf("the contents of '%s' %s changed upstream", e.Name, e.Version)
+	}
+	return fmt.Sprintf("%s (checksum %s, yuki.lock has %s); if this is expected, run again with --update-checksum %s",
+		msg, shortIntegrity(e.Actual), shortIntegrity(e.Expected), e.Name)
+}
+
+func shortIntegrity(value string) string {
+	algorithm, checksum, err := integrity.Parse(value)
+	if err != nil {
+		return value
+	}
+	return integrity.Format(algorithm, shortSHA(checksum))
+}
+
+func shortSHA(value string) string {
+	if len(value) > 12 {
+		return value[:12]
+	}
+	return value
+}
+
+// checkTrusted compares a freshly fetched candidate with the checksum
+// yuki.lock records for the same version. Branches and the latest commit are
+// expected to change, and so are packages named with UpdateChecksum.
+func (r *Resolver) checkTrusted(name string, c candidate, result *fetch.FetchResult) error {
+	lockFile := r.trusted
+	if lockFile == nil {
+		lockFile = r.lockFile
+	}
+	if lockFile == nil || r.updateChecksum[name] {
+		return nil
+	}
+
+	locked, exists := lockFile.Find(name)
+	if !exists || locked.Integrity == "" {
+		return nil
+	}
+	switch locked.Ref {
+	case manifest.RefVersion, manifest.RefTag, manifest.RefRev:
+	default:
+		return nil
+	}
+	if !sameVersion(locked.Version, c.version) || !source.Matches(c.pinned, locked.Source) {
+		return nil
+	}
+
+	algorithm, checksum, err := integrity.Parse(locked.Integrity)
+	if err != nil {
+		return nil
+	}
+	expected := integrity.Format(algorithm, checksum)
+	actual := result.Integrity(algorithm)
+	if actual == "" || actual == expected {
+		return nil
+	}
+	return &ChecksumError{
+		Name:          name,
+		Version:       locked.Version,
+		LockedCommit:  locked.Commit,
+		FetchedCommit: result.CommitSHA,
+		Expected:      expected,
+		Actual:        actual,
+	}
+}
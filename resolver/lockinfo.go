@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"strings"
+
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+	"yuki_zpm.org/workspace"
+)
+
+// refKind tells how the version of sel was chosen.
+func refKind(sel *selection) string {
+	dep := sel.pinned
+	switch {
+	case dep.Path != "":
+		return manifest.RefPath
+	case dep.Rev != "":
+		return manifest.RefRev
+	case dep.Tag != "":
+		return manifest.RefTag
+	case dep.Branch != "" || dep.UseLatestCommit:
+		return manifest.RefBranch
+	}
+
+	// Without a version requirement the latest release is used, or the
+	// default branch when there is none.
+	if _, err := semver.ParseVersion(sel.version); err != nil && dep.Archive == "" {
+		return manifest.RefBranch
+	}
+	return manifest.RefVersion
+}
+
+// constraint lists the distinct requirements placed on name, in the order
+// they were added.
+func (s *solver) constraint(name string) string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, req := range s.reqs[name] {
+		value := requirementValue(req.dep)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+	return strings.Join(values, ", ")
+}
+
+// requirementValue is what a manifest entry asks for: a version range, tag,
+// branch or rev. Path dependencies and "latest commit" have no constraint.
+func requirementValue(dep manifest.Dependency) string {
+	switch {
+	case dep.Path != "" || dep.UseLatestCommit:
+		return ""
+	case hasExplicitRef(dep):
+		return refValue(dep)
+	default:
+		return dep.Version
+	}
+}
+
+// dependencyKinds tells for every selected package whether the members use
+// it as a normal, build or dev dependency. Packages inherit the kind of the
+// dependents they are reached through, and normal wins over build, which
+// wins over dev.
+func (s *solver) dependencyKinds(members []workspace.Member) map[string]string {
+	rank := map[string]int{manifest.KindNormal: 0, manifest.KindBuild: 1, manifest.KindDev: 2}
+	kinds := make(map[string]string)
+
+	var visit func(name, kind string)
+	visit = func(name, kind string) {
+		if current, seen := kinds[name]; seen && rank[current] <= rank[kind] {
+			return
+		}
+		kinds[name] = kind
+		if sel, exists := s.selected[name]; exists {
+			for dep := range sel.deps {
+				visit(dep, kind)
+			}
+		}
+	}
+
+	for _, member := range members {
+		m := member.Manifest
+		for name := range m.RuntimeDependencies() {
+			visit(name, manifest.KindNormal)
+		}
+		for name := range m.BuildDependencies() {
+			visit(name, manifest.KindBuild)
+		}
+		for name := range m.DevDependencies() {
+			visit(name, manifest.KindDev)
+		}
+	}
+
+	return kinds
+}
@@ -0,0 +1,252 @@
+package resolver
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"yuki_zpm.org/internal/vendor"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+	"yuki_zpm.org/source"
+	"yuki_zpm.org/workspace"
+)
+
+// Kinds of requirement an OutdatedInfo can describe.
+const (
+	OutdatedVersion = "version"
+	OutdatedTag     = "tag"
+	OutdatedBranch  = "branch"
+	OutdatedRev     = "rev"
+	// OutdatedCommit is a package locked at a commit of its default branch
+	// because it has no semantic version tags.
+	OutdatedCommit = "commit"
+)
+
+// OutdatedInfo compares the locked version of a package with the newest one
+// its requirements allow (Wanted) and the newest one published (Latest).
+// Packages that follow a branch or commit compare commits instead.
+type OutdatedInfo struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Ref     string `json:"ref,omitempty"`
+	Current string `json:"current"`
+	Wanted  string `json:"wanted"`
+	Latest  string `json:"latest"`
+	Source  string `json:"source"`
+	Direct  bool   `json:"direct"`
+
+	Outdated bool `json:"outdated"`
+	// Error is set when the source could not be queried.
+	Error string `json:"error,omitempty"`
+}
+
+// CheckOutdated reports on every package of lockFile other than path
+// packages. Requirements come from the members' manifests and, when
+// UseVendored was called, from the vendored manifests of locked packages.
+func (r *Resolver) CheckOutdated(members []workspace.Member, lockFile *manifest.LockFile) []OutdatedInfo {
+	reqs, direct := r.lockedRequirements(members, lockFile)
+
+	infos := make([]OutdatedInfo, len(lockFile.Package))
+	work := newWorkGroup(r.jobs)
+	var wg sync.WaitGroup
+
+	for i, pkg := range lockFile.Package {
+		wg.Add(1)
+		go func(i int, pkg manifest.LockedPackage) {
+			defer wg.Done()
+			work.limit(func() (interface{}, error) {
+				infos[i] = checkOutdated(pkg, reqs[pkg.Name])
+				infos[i].Direct = direct[pkg.Name]
+				return nil, nil
+			})
+		}(i, pkg)
+	}
+	wg.Wait()
+
+	var result []OutdatedInfo
+	for _, info := range infos {
+		if info.Kind != "" {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// lockedRequirements collects what every dependent asks of each locked
+// package, and which packages a member depends on directly.
+func (r *Resolver) lockedRequirements(members []workspace.Member, lockFile *manifest.LockFile) (map[string][]manifest.Dependency, map[string]bool) {
+	reqs := make(map[string][]manifest.Dependency)
+	direct := make(map[string]bool)
+
+	add := func(deps map[string]manifest.Dependency) {
+		for _, name := range manifest.SortedDependencyNames(deps) {
+			dep, _ := r.applyPatch(deps[name])
+			reqs[name] = append(reqs[name], dep)
+		}
+	}
+
+	for _, member := range members {
+		deps := member.Manifest.GetAllDependencies()
+		for name := range deps {
+			direct[name] = true
+		}
+		add(deps)
+	}
+
+	if r.vendorRoot != "" {
+		for _, pkg := range lockFile.Package {
+			deps, _, err := loadPackageDependencies(filepath.Join(r.vendorRoot, vendor.VendorDir, pkg.Name))
+			if err == nil {
+				add(deps)
+			}
+		}
+	}
+
+	return reqs, direct
+}
+
+func checkOutdated(pkg manifest.LockedPackage, reqs []manifest.Dependency) OutdatedInfo {
+	info := OutdatedInfo{Name: pkg.Name, Source: pkg.Source, Current: pkg.Version}
+
+	if strings.HasPrefix(pkg.Source, manifest.SourcePath+"+") {
+		return OutdatedInfo{}
+	}
+
+	src, err := source.Parse(pkg.Source)
+	if err != nil {
+		info.Kind = OutdatedVersion
+		info.Error = err.Error()
+		return info
+	}
+
+	var ref *manifest.Dependency
+	for i := range reqs {
+		if hasExplicitRef(reqs[i]) {
+			ref = &reqs[i]
+			break
+		}
+	}
+
+	switch {
+	case ref != nil && ref.Branch != "":
+		info.Kind = OutdatedBranch
+		info.Ref = ref.Branch
+		checkCommit(&info, src, pkg, ref.Branch)
+	case ref != nil && (ref.Rev != "" || ref.UseLatestCommit):
+		info.Kind = OutdatedRev
+		info.Ref = ref.Rev
+		checkCommit(&info, src, pkg, "")
+		// A pinned commit only moves when the manifest changes.
+		info.Wanted = info.Current
+	case ref != nil && ref.Tag != "":
+		info.Kind = OutdatedTag
+		info.Ref = ref.Tag
+		checkVersions(&info, src, nil)
+		info.Wanted = info.Current
+	default:
+		info.Kind = OutdatedVersion
+		checkVersions(&info, src, reqs)
+		if info.Error == "" && info.Latest == "" {
+			// Without semantic version tags the package was locked at
+			// the head of the default branch.
+			info.Kind = OutdatedCommit
+			checkCommit(&info, src, pkg, "")
+		}
+	}
+
+	if info.Error == "" {
+		info.Outdated = !sameVersion(info.Current, info.Wanted) || !sameVersion(info.Current, info.Latest)
+	}
+	return info
+}
+
+// checkCommit compares the locked commit with the head of branch, or of the
+// default branch when branch is empty.
+func checkCommit(info *OutdatedInfo, src source.Source, pkg manifest.LockedPackage, branch string) {
+	info.Current = pkg.Commit
+	if info.Current == "" {
+		info.Current = pkg.Version
+	}
+
+	branches, ok := src.(source.Branches)
+	if !ok {
+		info.Wanted = info.Current
+		info.Latest = info.Current
+		return
+	}
+
+	commit, err := branches.BranchCommit(branch)
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	info.Wanted = commit
+	info.Latest = commit
+}
+
+// checkVersions fills in the newest version allowed by reqs and the newest
+// stable version. Neither is set when the source has no semantic versions.
+func checkVersions(info *OutdatedInfo, src source.Source, reqs []manifest.Dependency) {
+	versions, err := src.ListVersions()
+	if err != nil {
+		info.Error = err.Error()
+		return
+	}
+	if len(versions) == 0 {
+		return
+	}
+	source.SortVersions(versions)
+
+	current, currentErr := semver.ParseVersion(info.Current)
+	newer := func(v source.Version) bool {
+		return currentErr != nil || v.Compare(current) > 0
+	}
+
+	latest, found := source.LatestStable(versions)
+	if !found {
+		latest = versions[0]
+	}
+	info.Latest = info.Current
+	if newer(latest) {
+		info.Latest = versionName(latest, info.Kind)
+	}
+
+	var ranges []requirement
+	for _, dep := range reqs {
+		if isRangeRequirement(dep) {
+			ranges = append(ranges, requirement{dep: dep})
+		}
+	}
+	if len(ranges) == 0 {
+		info.Wanted = info.Latest
+		return
+	}
+
+	info.Wanted = info.Current
+	for i := range versions {
+		c := candidate{version: versions[i].String(), semver: &versions[i].Version, unstable: versions[i].Unstable}
+		ok := true
+		for _, req := range ranges {
+			if !allows(req, c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			if newer(versions[i]) {
+				info.Wanted = versionName(versions[i], info.Kind)
+			}
+			break
+		}
+	}
+}
+
+// versionName returns how a version is written for kind: tags by their
+// name and everything else the way yuki.lock records versions.
+func versionName(v source.Version, kind string) string {
+	if kind == OutdatedTag && v.Tag != "" {
+		return v.Tag
+	}
+	return v.String()
+}
@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// errStopped is the result of work that was started after the workGroup was
+// stopped.
+var errStopped = errors.New("resolution has finished")
+
+// call is the shared result of one unit of resolver work.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func (c *call) wait() (interface{}, error) {
+	<-c.done
+	return c.value, c.err
+}
+
+// workGroup runs resolver work in the background and memoizes it by key, so
+// a prefetch and the solver asking for the same thing share one result.
+// Goroutines are cheap; the number of network and git operations running at
+// once is bounded by the semaphore, which is only held around the leaf work.
+// Every goroutine is tracked, so that stop can wait for all of them.
+type workGroup struct {
+	sem     chan struct{}
+	mu      sync.Mutex
+	calls   map[string]*call
+	running sync.WaitGroup
+	stopped atomic.Bool
+}
+
+func newWorkGroup(jobs int) *workGroup {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	return &workGroup{
+		sem:   make(chan struct{}, jobs),
+		calls: make(map[string]*call),
+	}
+}
+
+// start begins computing key in the background unless it is already known.
+func (g *workGroup) start(key string, fn func() (interface{}, error)) *call {
+	g.mu.Lock()
+	if c, exists := g.calls[key]; exists {
+		g.mu.Unlock()
+		return c
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	g.background(func() {
+		defer close(c.done)
+		c.value, c.err = fn()
+	})
+	return c
+}
+
+// background runs fn in a goroutine that stop waits for.
+func (g *workGroup) background(fn func()) {
+	g.running.Add(1)
+	go func() {
+		defer g.running.Done()
+		fn()
+	}()
+}
+
+// stop cancels work that has not taken a worker slot yet and waits for the
+// rest to finish, so nothing is still writing to the cache once the
+// resolution is used.
+func (g *workGroup) stop() {
+	g.stopped.Store(true)
+	g.running.Wait()
+}
+
+// do computes key, or waits for an earlier computation of it to finish.
+func (g *workGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.start(key, fn).wait()
+}
+
+// limit runs fn while holding one of the worker slots.
+func (g *workGroup) limit(fn func() (interface{}, error)) (interface{}, error) {
+	g.sem <- struct{}{}
+	defer func() { <-g.sem }()
+	if g.stopped.Load() {
+		return nil, errStopped
+	}
+	return fn()
+}
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"path/filepath"
+
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/source"
+)
+
+// SetPatches replaces the source of every dependency matching a key of
+// patches, such as "github.com/owner/repo", wherever it appears in the graph,
+// including inside other packages. Relative paths in patches are relative to
+// the project root.
+func (r *Resolver) SetPatches(patches map[string]manifest.Dependency) {
+	r.patches = make(map[string]manifest.Dependency, len(patches))
+	for location, dep := range patches {
+		r.patches[source.NormalizeIdentity(location)] = dep
+	}
+}
+
+// applyPatch returns dep pointed at its patched source together with the
+// identity of the source it replaced, or dep itself and an empty string when
+// no patch applies.
+//
+// The dependent's version requirement is kept unless the patch names a
+// version, tag, branch or rev of its own.
+func (r *Resolver) applyPatch(dep manifest.Dependency) (manifest.Dependency, string) {
+	identity := source.Identity(dep)
+	if identity == "" {
+		return dep, ""
+	}
+	p, exists := r.patches[identity]
+	if !exists {
+		return dep, ""
+	}
+
+	patched := dep
+	patched.Git = p.Git
+	patched.Archive = p.Archive
+	patched.Path = p.Path
+	patched.BaseDir = ""
+	if p.Path != "" && !filepath.IsAbs(p.Path) {
+		patched.Path = filepath.ToSlash(filepath.Clean(p.Path))
+		patched.BaseDir = r.root
+	}
+
+	if p.Version != "" || hasExplicitRef(p) {
+		patched.Version = p.Version
+		patched.Branch = p.Branch
+		patched.Tag = p.Tag
+		patched.Rev = p.Rev
+		patched.UseLatestCommit = false
+	}
+	if p.RootFile != "" {
+		patched.RootFile = p.RootFile
+	}
+	patched.Prerelease = dep.Prerelease || p.Prerelease
+
+	return patched, identity
+}
@@ -1,25 +1,58 @@
 package resolver
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strings"
 
-	"yuki_zpm.org/github"
+	"yuki_zpm.org/fetch"
+	"yuki_zpm.org/integrity"
+	"yuki_zpm.org/internal/vendor"
 	"yuki_zpm.org/logger"
 	"yuki_zpm.org/manifest"
 	"yuki_zpm.org/semver"
+	"yuki_zpm.org/source"
+	"yuki_zpm.org/workspace"
 )
 
 type Resolver struct {
-	githubClient *github.Client
+	fetcher    *fetch.Fetcher
+	root       string
+	lockFile   *manifest.LockFile
+	vendorRoot string
+	offline    bool
+	jobs       int
+	patches    map[string]manifest.Dependency
+	zigVersion string
+	precise    map[string]string
+
+	// trusted holds the checksums fetched packages are checked against,
+	// see TrustLockFile.
+	trusted        *manifest.LockFile
+	updateChecksum map[string]bool
 }
 
 type ResolvedDependency struct {
-	Name     string
-	Version  string
-	Source   string
-	Checksum string
-	Deps     []string
+	Name       string
+	Version    string
+	Source     string
+	Checksum   string
+	ZigHash    string
+	CommitSHA  string
+	Path       string
+	Dependency manifest.Dependency
+	Deps       []string
+	Vendored   bool
+	Local      bool
+	// Patched is the source a [patch] entry replaced, if any.
+	Patched    string
+	// Ref, Constraint and Kind are recorded in yuki.lock as described
+	// there.
+	Ref        string
+	Constraint string
+	Kind       string
 }
 
 type Resolution struct {
@@ -28,26 +61,136 @@ type Resolution struct {
 
 func New() *Resolver {
 	return &Resolver{
-		githubClient: github.NewClient(),
+		fetcher: fetch.NewFetcher(),
+		root:    ".",
+	}
+}
+
+// SetProjectRoot sets the directory path dependencies of the manifest are
+// relative to. It defaults to the working directory.
+func (r *Resolver) SetProjectRoot(projectRoot string) {
+	r.root = projectRoot
+}
+
+// UseLockFile makes the resolver keep the versions recorded in lockFile for
+// every package whose requirements they still satisfy. Unless TrustLockFile
+// is called, fetched packages are also checked against its checksums.
+func (r *Resolver) UseLockFile(lockFile *manifest.LockFile) {
+	r.lockFile = lockFile
+}
+
+// TrustLockFile makes the resolver refuse a package whose contents differ
+// from the checksum lockFile records for the same version, e.g. because its
+// tag was moved upstream.
+func (r *Resolver) TrustLockFile(lockFile *manifest.LockFile) {
+	r.trusted = lockFile
+}
+
+// UpdateChecksum makes the resolver fetch the package name again from its
+// tag or version instead of the locked commit and accept its contents even
+// if they no longer match yuki.lock.
+func (r *Resolver) UpdateChecksum(name string) {
+	if r.updateChecksum == nil {
+		r.updateChecksum = make(map[string]bool)
+	}
+	r.updateChecksum[name] = true
+	r.fetcher.SetRefresh(name)
+}
+
+// UseVendored lets locked packages be read from the project's yuki_modules
+// directory when the vendored copy matches the lock file integrity.
+func (r *Resolver) UseVendored(projectRoot string) {
+	r.vendorRoot = projectRoot
+}
+
+// SetOffline forbids network access. Only locked packages that are vendored
+// or cached can be resolved.
+func (r *Resolver) SetOffline(offline bool) {
+	r.offline = offline
+	r.fetcher.SetOffline(offline)
+}
+
+// SetZigVersion makes the resolver skip package versions whose zig_version
+// does not fit the given Zig version. An empty version disables the check.
+func (r *Resolver) SetZigVersion(version string) {
+	r.zigVersion = version
+}
+
+// SetPrecise makes the resolver select exactly version for the package name,
+// which must still satisfy every requirement on it.
+func (r *Resolver) SetPrecise(name, version string) {
+	if r.precise == nil {
+		r.precise = make(map[string]string)
 	}
+	r.precise[name] = version
+}
+
+// SetJobs bounds how many packages are listed or fetched at the same time.
+// Zero or less uses one job per CPU.
+func (r *Resolver) SetJobs(jobs int) {
+	r.jobs = jobs
 }
 
 func (r *Resolver) Resolve(m *manifest.Manifest) (*Resolution, error) {
+	return r.ResolveWorkspace([]workspace.Member{{Name: m.Package.Name, Dir: r.root, Manifest: m}})
+}
+
+// ResolveWorkspace resolves the dependencies of several packages together, so
+// that every package they share is selected at a single version.
+func (r *Resolver) ResolveWorkspace(members []workspace.Member) (*Resolution, error) {
 	logger.Info("Resolving dependencies...")
-	
-	resolved := make(map[string]ResolvedDependency)
 
-	allDeps := m.GetAllDependencies()
-	
-	for name, dep := range allDeps {
-		if err := r.resolveDependency(name, dep, resolved); err != nil {
-			return nil, fmt.Errorf("failed to resolve dependency '%s': %w", name, err)
+	s := newSolver(r)
+	roots := make(map[string]manifest.Dependency)
+
+	for _, member := range members {
+		deps, err := r.anchorPaths(member.Manifest.GetAllDependencies(), member.Dir)
+		if err != nil {
+			return nil, err
 		}
+		s.addRequirements("", member.Name, deps)
+		for name, dep := range deps {
+			roots[name] = dep
+		}
+	}
+
+	err := s.checkRoots()
+	if err == nil {
+		err = s.solve()
+	}
+	s.work.stop()
+	if err != nil {
+		if errors.Is(err, errBacktrack) && s.conflict != nil {
+			return nil, s.conflict
+		}
+		return nil, err
+	}
+
+	if cycle := s.findCycle(manifest.SortedDependencyNames(roots)); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
 	}
 	
+	kinds := s.dependencyKinds(members)
+
 	var deps []ResolvedDependency
-	for _, dep := range resolved {
-		deps = append(deps, dep)
+	for name, sel := range s.selected {
+		deps = append(deps, ResolvedDependency{
+			Name:       name,
+			Version:    sel.version,
+			Source:     source.Describe(sel.pinned),
+			Checksum:   sel.result.Checksum,
+			ZigHash:    sel.result.ZigHash,
+			CommitSHA:  sel.result.CommitSHA,
+			Path:       sel.result.Path,
+			Dependency: sel.pinned,
+			Deps:       manifest.SortedDependencyNames(sel.deps),
+			Vendored:   sel.vendored,
+			Local:      sel.pinned.Path != "",
+			Patched:    s.patchedSource(name),
+			Ref:        refKind(sel),
+			Constraint: s.constraint(name),
+			Kind:       kinds[name],
+		})
 	}
 	
 	sort.Slice(deps, func(i, j int) bool {
@@ -59,144 +202,147 @@ func (r *Resolver) Resolve(m *manifest.Manifest) (*Resolution, error) {
 	return &Resolution{Dependencies: deps}, nil
 }
 
-func (r *Resolver) resolveDependency(name string, dep manifest.Dependency, resolved map[string]ResolvedDependency) error {
-	if _, exists := resolved[name]; exists {
-		return nil
-	}
-	
-	logger.Debug("Resolving dependency: %s", name)
-	
-	owner, repo, err := github.ParseRepoURL(dep.Git)
-	if err != nil {
-		return fmt.Errorf("invalid git URL: %w", err)
-	}
-	
-	resolvedVersion, err := r.resolveVersion(owner, repo, dep)
-	if err != nil {
-		return fmt.Errorf("failed to resolve version: %w", err)
+// LockFile builds the lock file describing this resolution.
+func (res *Resolution) LockFile() *manifest.LockFile {
+	lockFile := &manifest.LockFile{
+		Metadata: manifest.LockMetadata{Version: manifest.LockFileVersion},
+		Package:  []manifest.LockedPackage{},
 	}
 
-	resolvedDep := ResolvedDependency{
-		Name:     name,
-		Version:  resolvedVersion,
-		Source:   dep.Git,
-		Checksum: "", 
-		Deps:     []string{}, 
+	for _, dep := range res.Dependencies {
+		pkg := manifest.LockedPackage{
+			Name:       dep.Name,
+			Version:    dep.Version,
+			Source:     dep.Source,
+			Ref:        dep.Ref,
+			Constraint: dep.Constraint,
+			Commit:     dep.CommitSHA,
+			Kind:       dep.Kind,
+			Deps:       dep.Deps,
+			Patched:    dep.Patched,
+		}
+		// New entries use Zig's package hash, so they can be compared with
+		// build.zig.zon. Vendored copies keep the scheme they were locked
+		// with.
+		switch {
+		case dep.ZigHash != "":
+			pkg.Integrity = integrity.Format(integrity.ZigHash, dep.ZigHash)
+		case dep.Checksum != "":
+			pkg.Integrity = integrity.Format(integrity.SHA256, dep.Checksum)
+		}
+		lockFile.Package = append(lockFile.Package, pkg)
 	}
-	
-	resolved[name] = resolvedDep
-	
-	return nil
+
+	return lockFile
 }
 
-func (r *Resolver) resolveVersion(owner, repo string, dep manifest.Dependency) (string, error) {
-	if dep.Rev != "" {
-		return dep.Rev, nil
-	}
-	if dep.Tag != "" {
-		return dep.Tag, nil
+// vendoredCopy returns the already vendored copy of a locked package if its
+// contents still match the lock file, as yuki verify checks them. A copy
+// whose files were edited is reported and replaced.
+func (r *Resolver) vendoredCopy(name string, c candidate) *fetch.FetchResult {
+	if r.vendorRoot == "" || c.integrity == "" {
+		return nil
 	}
-	if dep.Branch != "" {
-		return dep.Branch, nil
+
+	drift, err := vendor.New().Verify(r.vendorRoot, name, c.integrity)
+	switch {
+	case err != nil:
+		logger.Debug("Could not verify vendored copy of '%s': %v", name, err)
+		return nil
+	case drift.Edited():
+		logger.Warn("Vendored copy of '%s' was modified (%s), installing it again", name, drift.Summary())
+		return nil
+	case drift.Changed():
+		logger.Debug("Vendored copy of '%s' does not match yuki.lock", name)
+		return nil
 	}
 
-	if dep.UseLatestCommit {
-		logger.Debug("Using latest commit for %s/%s", owner, repo)
-		return "main", nil
+	result := &fetch.FetchResult{
+		Path:      filepath.Join(r.vendorRoot, vendor.VendorDir, name),
+		Version:   c.version,
+		CommitSHA: c.commit,
 	}
+	if algorithm, checksum, err := integrity.Parse(c.integrity); err == nil && algorithm == integrity.ZigHash {
+		result.ZigHash = checksum
+	} else {
+		result.Checksum = checksum
+	}
+	return result
+}
 
-	if dep.Version != "" {
-		if dep.Version == "latest" {
-			if release, err := r.githubClient.GetLatestRelease(owner, repo); err == nil {
-				logger.Debug("Using latest release: %s", release.TagName)
-				return release.TagName, nil
-			}
-			return "", fmt.Errorf("no releases found for %s/%s", owner, repo)
-		}
-	
-		constraint, err := semver.ParseConstraint(dep.Version)
-		if err != nil {
-			return "", fmt.Errorf("invalid version constraint '%s': %w", dep.Version, err)
-		}
-	
-		versions, err := r.githubClient.GetAvailableVersions(owner, repo)
-		if err != nil {
-			return "", fmt.Errorf("failed to get available versions: %w", err)
-		}
-		
-		if len(versions) == 0 {
-			return "", fmt.Errorf("no semantic versions found for %s/%s", owner, repo)
-		}
+// loadPackageDependencies returns the runtime dependencies a fetched package
+// declares for any target, leaving out optional ones its default features do
+// not turn on, and the zig_version it needs. Packages without a yuki.toml
+// have no dependencies.
+func loadPackageDependencies(path string) (map[string]manifest.Dependency, string, error) {
+	if !manifest.Exists(path) {
+		return nil, "", nil
+	}
 
-		bestMatch, err := semver.FindBestMatch(constraint, versions)
-		if err != nil {
-			return "", fmt.Errorf("no version satisfies constraint '%s': %w", dep.Version, err)
-		}
-		
-		return bestMatch.String(), nil
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, "", err
 	}
 
-	if release, err := r.githubClient.GetLatestRelease(owner, repo); err == nil {
-		logger.Debug("Using latest release: %s", release.TagName)
-		return release.TagName, nil
+	features, err := m.ResolveFeatures(nil, true)
+	if err != nil {
+		return nil, "", err
 	}
 
-	logger.Debug("No releases found for %s/%s, using main branch", owner, repo)
-	return "main", nil
+	deps := make(map[string]manifest.Dependency)
+	for name, dep := range m.RuntimeDependencies() {
+		if !dep.Optional || features.Deps[name] {
+			deps[name] = dep
+		}
+	}
+	return deps, m.Package.ZigVersion, nil
 }
 
-func (r *Resolver) CheckForUpdates(lockFile *manifest.LockFile) ([]UpdateInfo, error) {
-	var updates []UpdateInfo
-	
-	for _, pkg := range lockFile.Package {
-		owner, repo, err := github.ParseRepoURL(pkg.Source)
-		if err != nil {
-			continue
-		}
-	
-		if release, err := r.githubClient.GetLatestRelease(owner, repo); err == nil {
-			currentVersion, err := semver.ParseVersion(pkg.Version)
+// anchorPaths rewrites the path dependencies declared by the package in dir
+// to be relative to the project root, which is how yuki.lock records them, so
+// every dependent naming the same directory agrees on its source.
+func (r *Resolver) anchorPaths(deps map[string]manifest.Dependency, dir string) (map[string]manifest.Dependency, error) {
+	anchored := make(map[string]manifest.Dependency, len(deps))
+
+	for name, dep := range deps {
+		if dep.Path != "" && !filepath.IsAbs(dep.Path) {
+			absDir, err := filepath.Abs(filepath.Join(dir, dep.Path))
 			if err != nil {
-				continue
+				return nil, fmt.Errorf("invalid path for '%s': %w", name, err)
 			}
-			
-			latestVersion, err := semver.ParseVersion(release.TagName)
+			absRoot, err := filepath.Abs(r.root)
 			if err != nil {
-				continue
+				return nil, err
 			}
-			
-			if latestVersion.Compare(currentVersion) > 0 {
-				updates = append(updates, UpdateInfo{
-					Name:           pkg.Name,
-					CurrentVersion: pkg.Version,
-					LatestVersion:  release.TagName,
-					Source:         pkg.Source,
-				})
+			rel, err := filepath.Rel(absRoot, absDir)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path for '%s': %w", name, err)
 			}
+			dep.Path = rel
+			dep.BaseDir = r.root
 		}
+		anchored[name] = dep
 	}
-	
-	return updates, nil
-}
 
-type UpdateInfo struct {
-	Name           string
-	CurrentVersion string
-	LatestVersion  string
-	Source         string
+	return anchored, nil
 }
 
+// ValidateDependencies checks that every dependency of the manifest in the
+// project root can be reached and has a valid version constraint.
 func (r *Resolver) ValidateDependencies(m *manifest.Manifest) error {
-	allDeps := m.GetAllDependencies()
+	allDeps, err := r.anchorPaths(m.GetAllDependencies(), r.root)
+	if err != nil {
+		return err
+	}
 	
 	for name, dep := range allDeps {
-		owner, repo, err := github.ParseRepoURL(dep.Git)
+		src, err := source.New(dep)
 		if err != nil {
-			return fmt.Errorf("dependency '%s' has invalid git URL: %w", name, err)
+			return fmt.Errorf("dependency '%s' has an invalid source: %w", name, err)
 		}
 	
-		if _, err := r.githubClient.GetRepository(owner, repo); err != nil {
-			return fmt.Errorf("dependency '%s' repository not accessible: %w", name, err)
+		if _, err := src.ListVersions(); err != nil {
+			return fmt.Errorf("dependency '%s' source %s is not accessible: %w", name, src.Describe(), err)
 		}
 
 		if dep.Version != "" && dep.Version != "latest" {
@@ -0,0 +1,900 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"yuki_zpm.org/fetch"
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+	"yuki_zpm.org/source"
+	"yuki_zpm.org/utils"
+)
+
+// requirement is a single edge of the dependency graph: a dependent asking
+// for a package through one of its manifest entries.
+type requirement struct {
+	dependent string
+	dep       manifest.Dependency
+
+	// from is the selected package that placed the requirement, or empty
+	// for the packages being resolved.
+	from string
+
+	// patched is the source a [patch] entry replaced in dep.
+	patched string
+}
+
+// candidate is one concrete version the solver may pick for a package.
+type candidate struct {
+	version string
+	semver  *semver.Version
+	pinned  manifest.Dependency
+
+	// unstable candidates are published as draft or prerelease GitHub
+	// releases and are only picked by requirements that opt in.
+	unstable bool
+
+	// locked candidates come from yuki.lock and are fetched by commit.
+	locked    bool
+	commit    string
+	integrity string
+}
+
+// selection is a candidate that has been fetched and whose own dependencies
+// have been added to the requirement set.
+type selection struct {
+	candidate
+	result     *fetch.FetchResult
+	deps       map[string]manifest.Dependency
+	vendored   bool
+	zigVersion string
+}
+
+// ConflictError explains why no version of a package satisfies every
+// requirement placed on it.
+type ConflictError struct {
+	Package      string
+	Requirements []string
+	Reason       string
+}
+
+func (e *ConflictError) Error() string {
+	var sb strings.Builder
+	if e.Reason != "" {
+		sb.WriteString(e.Reason)
+	} else {
+		sb.WriteString(fmt.Sprintf("cannot find a version of '%s' that satisfies every requirement", e.Package))
+	}
+	for _, req := range e.Requirements {
+		sb.WriteString("\n  ")
+		sb.WriteString(req)
+	}
+	return sb.String()
+}
+
+var errBacktrack = errors.New("no compatible version")
+
+// backjump is the errBacktrack of a part of the search that failed. culprits
+// are the selected packages whose versions caused the failure: choosing
+// another version of any other package cannot help, so the search goes
+// straight back to the last culprit that was selected.
+type backjump struct {
+	culprits map[string]bool
+}
+
+func (b *backjump) Error() string {
+	return errBacktrack.Error()
+}
+
+func (b *backjump) Unwrap() error {
+	return errBacktrack
+}
+
+// solver performs a depth-first search over package versions, always trying
+// the highest version first. When a package cannot be selected, the search
+// jumps back to the packages that caused the conflict, and the versions they
+// had are remembered as a combination that never needs to be tried again.
+//
+// The search itself is sequential, which keeps the result independent of
+// timing. Listing versions and fetching packages go through a work group,
+// and every newly required package is prefetched in the background so the
+// search rarely has to wait on the network.
+type solver struct {
+	resolver *Resolver
+	work     *workGroup
+	reqs     map[string][]requirement
+	selected map[string]*selection
+	conflict *ConflictError
+
+	// disproved lists the combinations of selections known to have no
+	// solution, each mapping packages to their selectionKey.
+	disproved []map[string]string
+
+	// zigRejected lists, per package, the versions skipped because they
+	// need a different Zig version.
+	zigRejected map[string][]string
+}
+
+func newSolver(r *Resolver) *solver {
+	return &solver{
+		resolver: r,
+		work:     newWorkGroup(r.jobs),
+		reqs:     make(map[string][]requirement),
+		selected: make(map[string]*selection),
+
+		zigRejected: make(map[string][]string),
+	}
+}
+
+// addRequirements adds the dependencies of the package from, which is empty
+// for the packages being resolved, to the requirement set.
+func (s *solver) addRequirements(from, dependent string, deps map[string]manifest.Dependency) {
+	for _, name := range manifest.SortedDependencyNames(deps) {
+		dep, patched := s.resolver.applyPatch(deps[name])
+		s.reqs[name] = append(s.reqs[name], requirement{dependent: dependent, dep: dep, from: from, patched: patched})
+		if _, done := s.selected[name]; !done {
+			s.prefetch(name)
+		}
+	}
+}
+
+// prefetch starts loading the candidate the search is most likely to pick
+// for name, based on the requirements known right now.
+func (s *solver) prefetch(name string) {
+	reqs := append([]requirement{}, s.reqs[name]...)
+
+	s.work.background(func() {
+		if s.work.stopped.Load() {
+			return
+		}
+		if c, ok := s.lockedCandidate(name, reqs); ok {
+			s.load(name, c)
+			return
+		}
+		if s.resolver.offline {
+			return
+		}
+		candidates, _, err := s.candidates(name, reqs)
+		if err == nil && len(candidates) > 0 {
+			s.load(name, candidates[0])
+		}
+	})
+}
+
+func (s *solver) removeRequirements(dependent string, deps map[string]manifest.Dependency) {
+	for name := range deps {
+		reqs := s.reqs[name]
+		for i := len(reqs) - 1; i >= 0; i-- {
+			if reqs[i].dependent == dependent {
+				reqs = append(reqs[:i], reqs[i+1:]...)
+				break
+			}
+		}
+		if len(reqs) == 0 {
+			delete(s.reqs, name)
+		} else {
+			s.reqs[name] = reqs
+		}
+	}
+}
+
+// patchedSource returns the source a [patch] entry replaced for name.
+func (s *solver) patchedSource(name string) string {
+	for _, req := range s.reqs[name] {
+		if req.patched != "" {
+			return req.patched
+		}
+	}
+	return ""
+}
+
+// nextPackage returns the alphabetically first package that is required but
+// not yet selected, which keeps the search order deterministic.
+func (s *solver) nextPackage() string {
+	var names []string
+	for name := range s.reqs {
+		if _, done := s.selected[name]; !done {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// checkRoots fails before the search starts when the requirements the
+// packages being resolved place on a dependency cannot be met, rather than
+// after every combination of versions of the other packages was tried.
+func (s *solver) checkRoots() error {
+	var names []string
+	for name := range s.reqs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var roots []requirement
+		for _, req := range s.reqs[name] {
+			if req.from == "" {
+				roots = append(roots, req)
+			}
+		}
+		if len(roots) == 0 || s.resolver.offline {
+			continue
+		}
+		if _, ok := s.lockedCandidate(name, roots); ok {
+			continue
+		}
+
+		candidates, reason, err := s.filteredCandidates(name, roots)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			s.recordConflict(name, reason)
+			return errBacktrack
+		}
+	}
+	return nil
+}
+
+func (s *solver) solve() error {
+	name := s.nextPackage()
+	if name == "" {
+		return nil
+	}
+
+	logger.Debug("Resolving dependency: %s", name)
+
+	// The requirements on name come from the workspace members and the
+	// packages in culprits, whose versions decide whether any version of
+	// name fits.
+	culprits := make(map[string]bool)
+	for _, req := range s.reqs[name] {
+		if req.from != "" {
+			culprits[req.from] = true
+		}
+	}
+	fail := func() error {
+		s.disprove(culprits)
+		return &backjump{culprits: culprits}
+	}
+
+	// attempt tries c and reports whether the next candidate should be
+	// tried, collecting the culprits of its failure.
+	attempt := func(c candidate) (bool, error) {
+		err := s.try(name, c)
+		var jump *backjump
+		if !errors.As(err, &jump) {
+			return false, err
+		}
+		if !jump.culprits[name] {
+			// Another version of name would fail the same way.
+			return false, err
+		}
+		for culprit := range jump.culprits {
+			if culprit != name {
+				culprits[culprit] = true
+			}
+		}
+		return true, nil
+	}
+
+	tried := ""
+	attempts := 0
+	zigRejected := len(s.zigRejected[name])
+	if c, ok := s.lockedCandidate(name, s.reqs[name]); ok {
+		if next, err := attempt(c); !next {
+			return err
+		}
+		tried = c.version
+		attempts++
+	}
+
+	if s.resolver.offline && s.reqs[name][0].dep.Path == "" {
+		if s.conflict == nil {
+			s.recordConflict(name, fmt.Sprintf("'%s' cannot be resolved from yuki.lock and network access is disabled", name))
+			s.conflict.Requirements = append(s.conflict.Requirements, s.zigRejected[name][zigRejected:]...)
+		}
+		return fail()
+	}
+
+	candidates, reason, err := s.filteredCandidates(name, s.reqs[name])
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		s.recordConflict(name, reason)
+		return fail()
+	}
+
+	for _, c := range candidates {
+		if c.version == tried {
+			continue
+		}
+		if next, err := attempt(c); !next {
+			return err
+		}
+		attempts++
+	}
+
+	if rejected := s.zigRejected[name][zigRejected:]; len(rejected) == attempts && s.conflict == nil {
+		s.recordConflict(name, fmt.Sprintf("no version of '%s' works with zig %s", name, s.resolver.zigVersion))
+		s.conflict.Requirements = append(s.conflict.Requirements, rejected...)
+	}
+
+	return fail()
+}
+
+// try selects a candidate and continues the search from there, undoing the
+// selection if the rest of the graph cannot be solved with it.
+func (s *solver) try(name string, c candidate) error {
+	sel, err := s.load(name, c)
+	if err != nil {
+		return err
+	}
+
+	if !s.supportsZig(name, sel) {
+		return &backjump{culprits: map[string]bool{name: true}}
+	}
+	if conflicting, ok := s.compatible(name, sel); !ok {
+		return &backjump{culprits: map[string]bool{name: true, conflicting: true}}
+	}
+
+	label := dependentLabel(name, sel.version)
+	s.selected[name] = sel
+	if culprits := s.isDisproved(); culprits != nil {
+		delete(s.selected, name)
+		logger.Debug("Skipping %s, which is known to conflict", label)
+		return &backjump{culprits: culprits}
+	}
+	s.addRequirements(name, label, sel.deps)
+
+	err = s.solve()
+	if err == nil || !errors.Is(err, errBacktrack) {
+		return err
+	}
+
+	s.removeRequirements(label, sel.deps)
+	delete(s.selected, name)
+	logger.Debug("Backtracking from %s", label)
+	return err
+}
+
+// disprove remembers that the current selections of culprits have no
+// solution.
+func (s *solver) disprove(culprits map[string]bool) {
+	nogood := make(map[string]string, len(culprits))
+	for name := range culprits {
+		sel, exists := s.selected[name]
+		if !exists {
+			return
+		}
+		nogood[name] = selectionKey(sel)
+	}
+	s.disproved = append(s.disproved, nogood)
+}
+
+// isDisproved returns the packages of a combination of the current
+// selections that is known to have no solution, or nil.
+func (s *solver) isDisproved() map[string]bool {
+	for _, nogood := range s.disproved {
+		matches := true
+		for name, key := range nogood {
+			if sel, exists := s.selected[name]; !exists || selectionKey(sel) != key {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			culprits := make(map[string]bool, len(nogood))
+			for name := range nogood {
+				culprits[name] = true
+			}
+			return culprits
+		}
+	}
+	return nil
+}
+
+// selectionKey identifies a selection in disproved combinations: its
+// version, source and the ref it was pinned to decide which requirements it
+// satisfies.
+func selectionKey(sel *selection) string {
+	return sel.version + " " + source.Describe(sel.pinned) + " " + refKey(sel.pinned)
+}
+
+// lockedCandidate returns the version recorded in yuki.lock for name when it
+// still satisfies every current requirement.
+func (s *solver) lockedCandidate(name string, reqs []requirement) (candidate, bool) {
+	if s.resolver.lockFile == nil {
+		return candidate{}, false
+	}
+
+	locked, exists := s.resolver.lockFile.Find(name)
+	if !exists {
+		return candidate{}, false
+	}
+	if precise, ok := s.resolver.precise[name]; ok && !sameVersion(locked.Version, precise) {
+		return candidate{}, false
+	}
+
+	c := candidate{
+		version:   locked.Version,
+		pinned:    reqs[0].dep,
+		locked:    true,
+		commit:    locked.Commit,
+		integrity: locked.Integrity,
+	}
+	if v, err := semver.ParseVersion(locked.Version); err == nil {
+		c.semver = &v
+	}
+
+	for _, req := range reqs {
+		// Path packages are always read from disk so edits show up.
+		if req.dep.Path != "" {
+			return candidate{}, false
+		}
+		if !source.Matches(req.dep, locked.Source) {
+			return candidate{}, false
+		}
+		if hasExplicitRef(req.dep) {
+			if req.dep.UseLatestCommit || refValue(req.dep) != locked.Version {
+				return candidate{}, false
+			}
+			c.pinned = req.dep
+			continue
+		}
+		if isRangeRequirement(req.dep) && !allows(req, c) {
+			return candidate{}, false
+		}
+	}
+
+	if isRangeRequirement(c.pinned) {
+		c.pinned.Version = locked.Version
+	}
+
+	// The version is fetched again from its tag, whose contents replace
+	// the locked ones.
+	if s.resolver.updateChecksum[name] {
+		c.commit = ""
+		c.integrity = ""
+	}
+
+	return c, true
+}
+
+// supportsZig reports whether sel can be built with the local Zig version.
+// Packages without a valid zig_version are assumed to work.
+func (s *solver) supportsZig(name string, sel *selection) bool {
+	zigVersion := s.resolver.zigVersion
+	if zigVersion == "" || sel.zigVersion == "" {
+		return true
+	}
+
+	ok, err := utils.ZigVersionSatisfies(sel.zigVersion, zigVersion)
+	if err != nil {
+		logger.Debug("Ignoring zig_version of '%s@%s': %v", name, sel.version, err)
+		return true
+	}
+	if !ok {
+		needs := utils.DescribeZigRequirement(sel.zigVersion)
+		logger.Debug("Skipping '%s@%s', which needs %s", name, sel.version, needs)
+		s.zigRejected[name] = append(s.zigRejected[name], fmt.Sprintf("%s %s needs %s", name, sel.version, needs))
+	}
+	return ok
+}
+
+// compatible reports whether the dependencies of sel agree with packages
+// that have already been selected, which have to come from the same source
+// and satisfy the requirements of sel. Otherwise it returns the package
+// that conflicts.
+func (s *solver) compatible(name string, sel *selection) (string, bool) {
+	label := dependentLabel(name, sel.version)
+	for _, depName := range manifest.SortedDependencyNames(sel.deps) {
+		chosen, exists := s.selected[depName]
+		if !exists {
+			continue
+		}
+		dep, patched := s.resolver.applyPatch(sel.deps[depName])
+		req := requirement{dependent: label, dep: dep, patched: patched}
+		if !source.Same(dep, chosen.pinned) {
+			s.recordConflict(depName, fmt.Sprintf("'%s' is required from different sources", depName), req)
+			return depName, false
+		}
+		if !allows(req, chosen.candidate) {
+			s.recordConflict(depName, "", req)
+			return depName, false
+		}
+	}
+	return "", true
+}
+
+// recordConflict remembers the first point at which the search got stuck,
+// which is what gets reported if no solution exists at all.
+func (s *solver) recordConflict(name, reason string, extra ...requirement) {
+	if s.conflict != nil {
+		return
+	}
+
+	reqs := append(append([]requirement{}, s.reqs[name]...), extra...)
+
+	conflict := &ConflictError{Package: name, Reason: reason}
+	for _, req := range reqs {
+		conflict.Requirements = append(conflict.Requirements, describeRequirement(name, req))
+	}
+	if conflict.Reason == "" && len(reqs) == 1 {
+		conflict.Reason = fmt.Sprintf("no available version of '%s' matches its requirement", name)
+	}
+	s.conflict = conflict
+}
+
+// candidates lists the versions of name that satisfy every requirement in
+// reqs, best candidate first. When there are none, the returned reason may
+// explain why.
+func (s *solver) candidates(name string, reqs []requirement) ([]candidate, string, error) {
+	src, err := source.New(reqs[0].dep)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid source for '%s': %w", name, err)
+	}
+	for _, req := range reqs[1:] {
+		if !source.Same(req.dep, reqs[0].dep) {
+			return nil, fmt.Sprintf("'%s' is required from different sources", name), nil
+		}
+	}
+
+	var refs, ranges []requirement
+	for _, req := range reqs {
+		switch {
+		case isRangeRequirement(req.dep):
+			ranges = append(ranges, req)
+		case hasExplicitRef(req.dep):
+			refs = append(refs, req)
+		}
+	}
+
+	if len(refs) > 0 {
+		key := refKey(refs[0].dep)
+		for _, req := range refs[1:] {
+			if refKey(req.dep) != key {
+				return nil, "", nil
+			}
+		}
+
+		version, err := s.refVersion(src, refs[0].dep)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to resolve version of '%s': %w", name, err)
+		}
+		c := candidate{version: version, pinned: refs[0].dep}
+		if v, err := semver.ParseVersion(version); err == nil {
+			c.semver = &v
+		}
+		for _, req := range ranges {
+			if !allows(req, c) {
+				return nil, "", nil
+			}
+		}
+		return []candidate{c}, "", nil
+	}
+
+	if len(ranges) == 0 {
+		version, err := s.refVersion(src, reqs[0].dep)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to resolve version of '%s': %w", name, err)
+		}
+		return []candidate{{version: version, pinned: reqs[0].dep}}, "", nil
+	}
+
+	versions, err := s.availableVersions(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get available versions of '%s': %w", name, err)
+	}
+
+	var result []candidate
+	for i := range versions {
+		v := versions[i].Version
+		pinned := ranges[0].dep
+		pinned.Version = v.String()
+		pinned.UseLatestCommit = false
+		c := candidate{version: v.String(), semver: &v, pinned: pinned, unstable: versions[i].Unstable}
+
+		ok := true
+		for _, req := range ranges {
+			if !allows(req, c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, c)
+		}
+	}
+
+	return result, "", nil
+}
+
+// filteredCandidates returns the candidates of name, keeping only the version
+// requested with SetPrecise if there is one.
+func (s *solver) filteredCandidates(name string, reqs []requirement) ([]candidate, string, error) {
+	candidates, reason, err := s.candidates(name, reqs)
+	if err != nil {
+		return nil, "", err
+	}
+	if precise, ok := s.resolver.precise[name]; ok {
+		candidates = preciseCandidates(candidates, precise)
+		if len(candidates) == 0 {
+			reason = fmt.Sprintf("version %s of '%s' does not exist or does not satisfy every requirement", precise, name)
+		}
+	}
+	return candidates, reason, nil
+}
+
+func (s *solver) availableVersions(src source.Source) ([]source.Version, error) {
+	value, err := s.work.do("versions:"+src.Describe(), func() (interface{}, error) {
+		return s.work.limit(func() (interface{}, error) {
+			versions, err := src.ListVersions()
+			if err != nil {
+				return nil, err
+			}
+
+			source.SortVersions(versions)
+			return versions, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]source.Version), nil
+}
+
+func (s *solver) refVersion(src source.Source, dep manifest.Dependency) (string, error) {
+	key := fmt.Sprintf("ref:%s:%s:%s", src.Describe(), refKey(dep), dep.Version)
+	value, err := s.work.do(key, func() (interface{}, error) {
+		return s.work.limit(func() (interface{}, error) {
+			rev, err := src.ResolveRef(dep)
+			if err != nil {
+				return nil, err
+			}
+			return rev.Version, nil
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// load fetches a candidate and reads the dependencies from its yuki.toml.
+// It may run concurrently for different candidates.
+func (s *solver) load(name string, c candidate) (*selection, error) {
+	value, err := s.work.do("package:"+name+"@"+c.version, func() (interface{}, error) {
+		return s.work.limit(func() (interface{}, error) {
+			return s.fetchCandidate(name, c)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*selection), nil
+}
+
+func (s *solver) fetchCandidate(name string, c candidate) (*selection, error) {
+	var result *fetch.FetchResult
+	vendored := false
+
+	if c.locked {
+		result = s.resolver.vendoredCopy(name, c)
+		vendored = result != nil
+	}
+
+	if result == nil {
+		fetchDep := c.pinned
+		if c.commit != "" {
+			fetchDep = manifest.Dependency{Git: c.pinned.Git, Archive: c.pinned.Archive, Rev: c.commit, RootFile: c.pinned.RootFile}
+		}
+
+		var err error
+		result, err = s.resolver.fetcher.FetchDependency(name, fetchDep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch '%s': %w", name, err)
+		}
+	}
+
+	deps, zigVersion, err := loadPackageDependencies(result.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of '%s': %w", name, err)
+	}
+
+	if c.pinned.Path != "" {
+		if deps, err = s.resolver.anchorPaths(deps, result.Path); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, depName := range manifest.SortedDependencyNames(deps) {
+			if deps[depName].Path != "" {
+				return nil, fmt.Errorf("'%s' depends on '%s' by path, which only works for packages that are themselves path dependencies", name, depName)
+			}
+		}
+	}
+
+	if c.semver == nil && !c.locked {
+		c.version = result.Version
+	}
+
+	if !vendored {
+		if err := s.resolver.checkTrusted(name, c, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return &selection{candidate: c, result: result, deps: deps, vendored: vendored, zigVersion: zigVersion}, nil
+}
+
+// findCycle returns the first dependency cycle among the selected packages.
+func (s *solver) findCycle(roots []string) []string {
+	state := make(map[string]int)
+	var stack []string
+	var cycle []string
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case 1:
+			for i, n := range stack {
+				if n == name {
+					cycle = append(append([]string{}, stack[i:]...), name)
+					return true
+				}
+			}
+		case 2:
+			return false
+		}
+
+		sel, exists := s.selected[name]
+		if !exists {
+			return false
+		}
+
+		state[name] = 1
+		stack = append(stack, name)
+		for _, dep := range manifest.SortedDependencyNames(sel.deps) {
+			if visit(dep) {
+				return true
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = 2
+		return false
+	}
+
+	for _, root := range roots {
+		if visit(root) {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func allows(req requirement, c candidate) bool {
+	if isRangeRequirement(req.dep) {
+		if c.semver == nil {
+			return false
+		}
+		if c.unstable && !req.dep.Prerelease {
+			return false
+		}
+		constraint, err := semver.ParseConstraint(req.dep.Version)
+		if err != nil {
+			return false
+		}
+		return constraint.Allows(*c.semver, req.dep.Prerelease)
+	}
+
+	if hasExplicitRef(req.dep) {
+		return refKey(req.dep) == refKey(c.pinned)
+	}
+
+	return true
+}
+
+// preciseCandidates keeps the candidates whose version is precise.
+func preciseCandidates(candidates []candidate, precise string) []candidate {
+	var result []candidate
+	for _, c := range candidates {
+		if sameVersion(c.version, precise) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// sameVersion compares two versions semantically when both parse, so that
+// "v1.2.0" and "1.2.0" are equal, and literally otherwise.
+func sameVersion(a, b string) bool {
+	if a == b {
+		return true
+	}
+	va, errA := semver.ParseVersion(a)
+	vb, errB := semver.ParseVersion(b)
+	return errA == nil && errB == nil && va.Compare(vb) == 0
+}
+
+// isRangeRequirement reports whether a dependency is constrained by a
+// semantic version range rather than a git reference.
+func isRangeRequirement(dep manifest.Dependency) bool {
+	return dep.Version != "" && dep.Version != "latest" && !hasExplicitRef(dep)
+}
+
+func hasExplicitRef(dep manifest.Dependency) bool {
+	return dep.Rev != "" || dep.Tag != "" || dep.Branch != "" || dep.UseLatestCommit
+}
+
+func refValue(dep manifest.Dependency) string {
+	switch {
+	case dep.Rev != "":
+		return dep.Rev
+	case dep.Tag != "":
+		return dep.Tag
+	case dep.Branch != "":
+		return dep.Branch
+	default:
+		return ""
+	}
+}
+
+func refKey(dep manifest.Dependency) string {
+	switch {
+	case dep.Rev != "":
+		return "rev:" + dep.Rev
+	case dep.Tag != "":
+		return "tag:" + dep.Tag
+	case dep.Branch != "":
+		return "branch:" + dep.Branch
+	case dep.UseLatestCommit:
+		return "latest-commit"
+	default:
+		return ""
+	}
+}
+
+func describeRequirement(name string, req requirement) string {
+	if req.patched != "" {
+		return fmt.Sprintf("%s needs %s %s (patched from %s)", req.dependent, name, DescribeSpec(req.dep), req.patched)
+	}
+	return fmt.Sprintf("%s needs %s %s", req.dependent, name, DescribeSpec(req.dep))
+}
+
+// DescribeSpec describes what a dependency asks for, such as "^1.2.0" or
+// "branch main".
+func DescribeSpec(dep manifest.Dependency) string {
+	switch {
+	case dep.Rev != "":
+		rev := dep.Rev
+		if len(rev) > 8 {
+			rev = rev[:8]
+		}
+		return "rev " + rev
+	case dep.Tag != "":
+		return "tag " + dep.Tag
+	case dep.Branch != "":
+		return "branch " + dep.Branch
+	case dep.UseLatestCommit:
+		return "latest commit"
+	case dep.Path != "":
+		return "path " + dep.Path
+	case dep.Version != "":
+		return dep.Version
+	default:
+		return "(any version)"
+	}
+}
+
+func dependentLabel(name, version string) string {
+	return name + " " + version
+}
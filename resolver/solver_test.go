@@ -0,0 +1,385 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+	"yuki_zpm.org/source"
+)
+
+// fakeRegistry serves packages from memory in place of git repositories.
+// Packages are found by the identity of their source, so every spelling of a
+// location reaches the same package.
+type fakeRegistry struct {
+	mu       sync.Mutex
+	packages map[string]map[string]map[string]manifest.Dependency
+	fetches  map[string]int
+}
+
+// fakeDep is a dependency on the package name of the registry.
+func fakeDep(name, version string) manifest.Dependency {
+	return manifest.Dependency{Git: "https://test.invalid/" + name, Version: version}
+}
+
+// newFakeRegistry replaces git sources with the registry for the duration
+// of the test.
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	reg := &fakeRegistry{
+		packages: make(map[string]map[string]map[string]manifest.Dependency),
+		fetches:  make(map[string]int),
+	}
+	source.Register(manifest.SourceGit,
+		func(dep manifest.Dependency) (source.Source, error) { return &fakeSource{reg: reg, dep: dep}, nil },
+		func(location string) manifest.Dependency { return manifest.Dependency{Git: location} })
+	t.Cleanup(func() {
+		source.Register(manifest.SourceGit,
+			func(dep manifest.Dependency) (source.Source, error) { return source.NewGitSource(dep.Git) },
+			func(location string) manifest.Dependency { return manifest.Dependency{Git: location} })
+	})
+
+	// Fetched packages are cached under the home directory.
+	t.Setenv("HOME", t.TempDir())
+	return reg
+}
+
+// add publishes version of the package at location with deps.
+func (reg *fakeRegistry) add(location, version string, deps map[string]manifest.Dependency) {
+	identity := source.NormalizeIdentity(location)
+	if reg.packages[identity] == nil {
+		reg.packages[identity] = make(map[string]map[string]manifest.Dependency)
+	}
+	reg.packages[identity][version] = deps
+}
+
+// publish adds the versions of the package name, each depending on deps.
+func (reg *fakeRegistry) publish(name string, deps map[string]manifest.Dependency, versions ...string) {
+	for _, version := range versions {
+		reg.add(fakeDep(name, "").Git, version, deps)
+	}
+}
+
+func (reg *fakeRegistry) fetchCount(name string) int {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.fetches[source.NormalizeIdentity(fakeDep(name, "").Git)]
+}
+
+type fakeSource struct {
+	reg *fakeRegistry
+	dep manifest.Dependency
+}
+
+func (s *fakeSource) identity() string {
+	return source.Identity(s.dep)
+}
+
+func (s *fakeSource) Describe() string {
+	return "git+" + s.dep.Git
+}
+
+func (s *fakeSource) Key() string {
+	return "fake/" + strings.NewReplacer("/", "_", ":", "_").Replace(s.identity())
+}
+
+func (s *fakeSource) ListVersions() ([]source.Version, error) {
+	var versions []source.Version
+	for version := range s.reg.packages[s.identity()] {
+		v, err := semver.ParseVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, source.Version{Version: v, Tag: "v" + version})
+	}
+	return versions, nil
+}
+
+func (s *fakeSource) ResolveRef(dep manifest.Dependency) (source.Revision, error) {
+	versions, err := s.ListVersions()
+	if err != nil {
+		return source.Revision{}, err
+	}
+	source.SortVersions(versions)
+
+	constraint := dep.Version
+	if constraint == "" || constraint == "latest" {
+		constraint = "*"
+	}
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return source.Revision{}, err
+	}
+	for _, v := range versions {
+		if c.Allows(v.Version, dep.Prerelease) {
+			return source.Revision{Ref: v.Tag, Version: v.String()}, nil
+		}
+	}
+	return source.Revision{}, fmt.Errorf("no version of %s matches %s", s.identity(), dep.Version)
+}
+
+func (s *fakeSource) Fetch(rev source.Revision, dir string) (string, error) {
+	s.reg.mu.Lock()
+	s.reg.fetches[s.identity()]++
+	s.reg.mu.Unlock()
+
+	version := strings.TrimPrefix(rev.Ref, "v")
+	deps, exists := s.reg.packages[s.identity()][version]
+	if !exists {
+		return "", fmt.Errorf("%s has no version %s", s.identity(), version)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	m := &manifest.Manifest{
+		Package:      manifest.PackageInfo{Name: s.identity(), Version: version},
+		Dependencies: deps,
+	}
+	return "", m.Save(dir)
+}
+
+// resolve resolves a project depending on deps and returns the selected
+// version of every package.
+func resolve(t *testing.T, deps map[string]manifest.Dependency) (map[string]string, error) {
+	t.Helper()
+	r := New()
+	r.SetProjectRoot(t.TempDir())
+	r.SetJobs(4)
+
+	m := &manifest.Manifest{
+		Package:      manifest.PackageInfo{Name: "app", Version: "0.1.0"},
+		Dependencies: deps,
+	}
+	resolution, err := r.Resolve(m)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string)
+	for _, dep := range resolution.Dependencies {
+		versions[dep.Name] = dep.Version
+	}
+	return versions, nil
+}
+
+func expectVersions(t *testing.T, got map[string]string, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("resolved %v, want %v", got, want)
+		return
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("resolved %v, want %v", got, want)
+			return
+		}
+	}
+}
+
+func TestSolverSourceSpellings(t *testing.T) {
+	for _, first := range []string{"a", "y"} {
+		t.Run(first, func(t *testing.T) {
+			reg := newFakeRegistry(t)
+			reg.add("/tmp/x", "1.0.0", nil)
+			reg.publish(first, map[string]manifest.Dependency{
+				"z": {Git: "file:///tmp/x", Version: "^1.0"},
+			}, "1.0.0")
+
+			got, err := resolve(t, map[string]manifest.Dependency{
+				first: fakeDep(first, "^1.0"),
+				"z":   {Git: "/tmp/x", Version: "^1.0"},
+			})
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			expectVersions(t, got, map[string]string{first: "1.0.0", "z": "1.0.0"})
+		})
+	}
+}
+
+func TestSolverDifferentSources(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.add("/tmp/x", "1.0.0", nil)
+	reg.add("/tmp/other", "1.0.0", nil)
+	reg.publish("a", map[string]manifest.Dependency{
+		"z": {Git: "/tmp/other", Version: "^1.0"},
+	}, "1.0.0")
+
+	_, err := resolve(t, map[string]manifest.Dependency{
+		"a": fakeDep("a", "^1.0"),
+		"z": {Git: "/tmp/x", Version: "^1.0"},
+	})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !strings.Contains(conflict.Error(), "different sources") {
+		t.Fatalf("got %v, want a conflict about different sources", err)
+	}
+}
+
+// versions returns n versions 1.0.0, 1.1.0, ...
+func versions(n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("1.%d.0", i))
+	}
+	return result
+}
+
+func TestSolverPicksHighestVersion(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.publish("a", map[string]manifest.Dependency{"b": fakeDep("b", "~1.1")}, "1.0.0", "1.2.0", "2.0.0")
+	reg.publish("b", nil, "1.0.0", "1.1.0", "1.1.5", "1.2.0")
+
+	got, err := resolve(t, map[string]manifest.Dependency{"a": fakeDep("a", "^1.0")})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	expectVersions(t, got, map[string]string{"a": "1.2.0", "b": "1.1.5"})
+}
+
+func TestSolverBacktracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages func(reg *fakeRegistry)
+		deps     map[string]manifest.Dependency
+		want     map[string]string
+	}{
+		{
+			// a 2.0.0 needs a c that the project does not allow.
+			name: "requirement of a newer version",
+			packages: func(reg *fakeRegistry) {
+				reg.publish("a", nil, "1.0.0")
+				reg.publish("a", map[string]manifest.Dependency{"c": fakeDep("c", "^2.0")}, "2.0.0")
+				reg.publish("c", nil, "1.0.0", "2.0.0")
+			},
+			deps: map[string]manifest.Dependency{"a": fakeDep("a", ">=1.0.0"), "c": fakeDep("c", "^1.0")},
+			want: map[string]string{"a": "1.0.0", "c": "1.0.0"},
+		},
+		{
+			// c 2.0.0 conflicts with the b selected before it.
+			name: "conflict with an earlier selection",
+			packages: func(reg *fakeRegistry) {
+				reg.publish("b", nil, "1.0.0", "2.0.0")
+				reg.publish("c", nil, "1.0.0")
+				reg.publish("c", map[string]manifest.Dependency{"b": fakeDep("b", "^1.0")}, "2.0.0")
+			},
+			deps: map[string]manifest.Dependency{"b": fakeDep("b", "*"), "c": fakeDep("c", "*")},
+			want: map[string]string{"b": "2.0.0", "c": "1.0.0"},
+		},
+		{
+			// m only fits b 1.x, which is selected two packages earlier.
+			name: "back to an earlier culprit",
+			packages: func(reg *fakeRegistry) {
+				reg.publish("b", nil, "1.0.0", "2.0.0")
+				reg.publish("k", nil, versions(8)...)
+				reg.publish("m", map[string]manifest.Dependency{"b": fakeDep("b", "^1.0")}, "1.0.0")
+			},
+			deps: map[string]manifest.Dependency{"b": fakeDep("b", "*"), "k": fakeDep("k", "*"), "m": fakeDep("m", "*")},
+			want: map[string]string{"b": "1.0.0", "k": "1.7.0", "m": "1.0.0"},
+		},
+		{
+			// Both x versions need a y the other package rules out.
+			name: "transitive conflicts",
+			packages: func(reg *fakeRegistry) {
+				reg.publish("w", map[string]manifest.Dependency{"y": fakeDep("y", "^1.0")}, "1.0.0")
+				reg.publish("w", map[string]manifest.Dependency{"y": fakeDep("y", "^2.0")}, "2.0.0")
+				reg.publish("x", map[string]manifest.Dependency{"y": fakeDep("y", "^2.0"), "z": fakeDep("z", "^2.0")}, "2.0.0")
+				reg.publish("x", map[string]manifest.Dependency{"y": fakeDep("y", "^1.0")}, "1.0.0")
+				reg.publish("y", nil, "1.0.0", "2.0.0")
+				reg.publish("z", nil, "1.0.0")
+			},
+			deps: map[string]manifest.Dependency{"w": fakeDep("w", "*"), "x": fakeDep("x", "*")},
+			want: map[string]string{"w": "1.0.0", "x": "1.0.0", "y": "1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newFakeRegistry(t)
+			tt.packages(reg)
+			got, err := resolve(t, tt.deps)
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			expectVersions(t, got, tt.want)
+		})
+	}
+}
+
+func TestSolverSkipsUnrelatedPackages(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.publish("b", nil, "1.0.0", "2.0.0")
+	reg.publish("k", nil, versions(8)...)
+	reg.publish("m", map[string]manifest.Dependency{"b": fakeDep("b", "^1.0")}, "1.0.0")
+
+	if _, err := resolve(t, map[string]manifest.Dependency{"b": fakeDep("b", "*"), "k": fakeDep("k", "*"), "m": fakeDep("m", "*")}); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	// Only b has to change, k keeps its first version.
+	if n := reg.fetchCount("k"); n != 1 {
+		t.Errorf("fetched k %d times, want 1", n)
+	}
+}
+
+func TestSolverUnsatisfiableRequirement(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.publish("a", nil, versions(8)...)
+	reg.publish("b", nil, versions(8)...)
+	reg.publish("c", nil, versions(3)...)
+
+	_, err := resolve(t, map[string]manifest.Dependency{
+		"a": fakeDep("a", "*"),
+		"b": fakeDep("b", "*"),
+		"c": fakeDep("c", "^2.0.0"),
+	})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got %v, want a conflict", err)
+	}
+	if conflict.Package != "c" || len(conflict.Requirements) != 1 || conflict.Requirements[0] != "app needs c ^2.0.0" {
+		t.Errorf("unexpected conflict: %+v", conflict)
+	}
+	for _, name := range []string{"a", "b"} {
+		if n := reg.fetchCount(name); n > 1 {
+			t.Errorf("fetched %s %d times, want at most 1", name, n)
+		}
+	}
+}
+
+func TestSolverUnsatisfiableTransitiveRequirement(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.publish("a", nil, versions(8)...)
+	reg.publish("b", nil, versions(8)...)
+	reg.publish("c", nil, versions(3)...)
+	reg.publish("x", map[string]manifest.Dependency{"c": fakeDep("c", "^2.0.0")}, versions(4)...)
+
+	_, err := resolve(t, map[string]manifest.Dependency{
+		"a": fakeDep("a", "*"),
+		"b": fakeDep("b", "*"),
+		"x": fakeDep("x", "*"),
+	})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Package != "c" {
+		t.Fatalf("got %v, want a conflict on c", err)
+	}
+	// No version of a or b can fix x, so they are not tried.
+	for _, name := range []string{"a", "b"} {
+		if n := reg.fetchCount(name); n > 1 {
+			t.Errorf("fetched %s %d times, want at most 1", name, n)
+		}
+	}
+}
+
+func TestSolverCycle(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.publish("a", map[string]manifest.Dependency{"b": fakeDep("b", "^1.0")}, "1.0.0")
+	reg.publish("b", map[string]manifest.Dependency{"a": fakeDep("a", "^1.0")}, "1.0.0")
+
+	_, err := resolve(t, map[string]manifest.Dependency{"a": fakeDep("a", "^1.0")})
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle detected: a -> b -> a") {
+		t.Fatalf("got %v, want a cycle", err)
+	}
+}
@@ -79,74 +79,300 @@ func (v Version) Compare(other Version) int {
 	}
 
 	
-	if v.Prerelease == "" && other.Prerelease != "" {
-		return 1 
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease orders prerelease strings as described by semver 2.0:
+// a version without a prerelease ranks higher, identifiers are compared one
+// by one, numeric identifiers numerically and below alphanumeric ones, and a
+// shorter list of otherwise equal identifiers ranks lower.
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
 	}
-	if v.Prerelease != "" && other.Prerelease == "" {
-		return -1 
+	if b == "" {
+		return -1
 	}
-	if v.Prerelease != other.Prerelease {
-		if v.Prerelease < other.Prerelease {
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bParts[i], 10, 64)
+		aIsNum := aErr == nil
+		bIsNum := bErr == nil
+
+		switch {
+		case aIsNum && bIsNum:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aIsNum:
 			return -1
+		case bIsNum:
+			return 1
+		default:
+			if cmp := strings.Compare(aParts[i], bParts[i]); cmp != 0 {
+				return cmp
+			}
 		}
-		return 1
 	}
 
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	}
 	return 0
 }
 
 
-func ParseConstraint(constraint string) (Constraint, error) {
+// ConstraintSet is a union of constraint groups. A version satisfies the set
+// when it satisfies every constraint of at least one group; an empty group
+// matches any version.
+type ConstraintSet struct {
+	Groups [][]Constraint
+}
+
+var partialVersionRegex = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?$`)
+
+// ParseConstraint parses a version requirement. It accepts single operators
+// (^1.2.0, ~1.2.0, >=1.0.0, =1.0.0), comma or space separated intersections
+// (">=1.2.0, <1.5.0"), wildcards (1.x, 1.2.*, *), hyphen ranges
+// (1.2.0 - 1.4.0) and alternatives joined by "||" (^1.0 || ^2.0).
+func ParseConstraint(constraint string) (ConstraintSet, error) {
 	constraint = strings.TrimSpace(constraint)
-	
-	if strings.HasPrefix(constraint, "^") {
-		version, err := ParseVersion(constraint[1:])
-		return Constraint{Operator: "^", Version: version}, err
+	if constraint == "" {
+		return ConstraintSet{}, fmt.Errorf("empty version constraint")
 	}
-	
-	if strings.HasPrefix(constraint, "~") {
-		version, err := ParseVersion(constraint[1:])
-		return Constraint{Operator: "~", Version: version}, err
+
+	var set ConstraintSet
+	for _, part := range strings.Split(constraint, "||") {
+		group, err := parseConstraintGroup(strings.TrimSpace(part))
+		if err != nil {
+			return ConstraintSet{}, err
+		}
+		set.Groups = append(set.Groups, group)
 	}
-	
-	if strings.HasPrefix(constraint, ">=") {
-		version, err := ParseVersion(constraint[2:])
-		return Constraint{Operator: ">=", Version: version}, err
+
+	return set, nil
+}
+
+func parseConstraintGroup(group string) ([]Constraint, error) {
+	if group == "" {
+		return nil, fmt.Errorf("empty constraint group")
 	}
-	
-	if strings.HasPrefix(constraint, "<=") {
-		version, err := ParseVersion(constraint[2:])
-		return Constraint{Operator: "<=", Version: version}, err
+
+	if fields := strings.Fields(group); len(fields) == 3 && fields[1] == "-" {
+		lower, err := expandPartial(">=", fields[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := expandPartial("<=", fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return append(lower, upper...), nil
 	}
-	
-	if strings.HasPrefix(constraint, ">") {
-		version, err := ParseVersion(constraint[1:])
-		return Constraint{Operator: ">", Version: version}, err
+
+	var tokens []string
+	for _, field := range strings.Fields(strings.ReplaceAll(group, ",", " ")) {
+		if len(tokens) > 0 && isOperator(tokens[len(tokens)-1]) {
+			tokens[len(tokens)-1] += field
+			continue
+		}
+		tokens = append(tokens, field)
 	}
-	
-	if strings.HasPrefix(constraint, "<") {
-		version, err := ParseVersion(constraint[1:])
-		return Constraint{Operator: "<", Version: version}, err
+
+	var result []Constraint
+	for _, token := range tokens {
+		op, version := splitOperator(token)
+		if version == "" {
+			return nil, fmt.Errorf("missing version after '%s'", op)
+		}
+		constraints, err := expandPartial(op, version)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, constraints...)
 	}
-	
-	if strings.HasPrefix(constraint, "=") {
-		version, err := ParseVersion(constraint[1:])
-		return Constraint{Operator: "=", Version: version}, err
+
+	return result, nil
+}
+
+func isOperator(token string) bool {
+	switch token {
+	case "^", "~", ">=", "<=", ">", "<", "=":
+		return true
 	}
-	
-	
-	version, err := ParseVersion(constraint)
-	return Constraint{Operator: "=", Version: version}, err
+	return false
+}
+
+func splitOperator(token string) (string, string) {
+	for _, op := range []string{">=", "<=", "^", "~", ">", "<", "="} {
+		if strings.HasPrefix(token, op) {
+			return op, strings.TrimSpace(token[len(op):])
+		}
+	}
+	return "", token
+}
+
+// expandPartial turns an operator applied to a possibly partial version
+// (1, 1.2, 1.x, 1.2.*, *) into primitive constraints on full versions.
+func expandPartial(op, version string) ([]Constraint, error) {
+	if full, err := ParseVersion(version); err == nil {
+		if op == "" {
+			op = "="
+		}
+		return []Constraint{{Operator: op, Version: full}}, nil
+	}
+
+	matches := partialVersionRegex.FindStringSubmatch(version)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid semantic version: %s", version)
+	}
+
+	var parts []int
+	for _, m := range matches[1:] {
+		if m == "" || m == "x" || m == "X" || m == "*" {
+			break
+		}
+		n, _ := strconv.Atoi(m)
+		parts = append(parts, n)
+	}
+
+	if len(parts) == 0 {
+		switch op {
+		case "", "=", ">=", "<=", "^", "~":
+			return []Constraint{}, nil
+		default:
+			return nil, fmt.Errorf("'%s%s' cannot match any version", op, version)
+		}
+	}
+
+	lower := Version{Major: parts[0]}
+	upper := Version{Major: parts[0] + 1}
+	if len(parts) == 2 {
+		lower.Minor = parts[1]
+		upper = Version{Major: parts[0], Minor: parts[1] + 1}
+	}
+
+	// Exclusive bounds end before the first prerelease, as in npm, so 1.x
+	// does not let 2.0.0-alpha in when prereleases are allowed.
+	below := func(v Version) Version {
+		v.Prerelease = "0"
+		return v
+	}
+
+	switch op {
+	case "", "=", "~":
+		return []Constraint{{Operator: ">=", Version: lower}, {Operator: "<", Version: below(upper)}}, nil
+	case "^":
+		if len(parts) == 2 && (lower.Major != 0 || lower.Minor != 0) {
+			return []Constraint{{Operator: "^", Version: lower}}, nil
+		}
+		return []Constraint{{Operator: ">=", Version: lower}, {Operator: "<", Version: below(upper)}}, nil
+	case ">=":
+		return []Constraint{{Operator: ">=", Version: lower}}, nil
+	case ">":
+		return []Constraint{{Operator: ">=", Version: upper}}, nil
+	case "<":
+		return []Constraint{{Operator: "<", Version: below(lower)}}, nil
+	case "<=":
+		return []Constraint{{Operator: "<", Version: below(upper)}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator '%s'", op)
+}
+
+// Satisfies reports whether version matches at least one group of the set.
+// Like Cargo and npm, a prerelease only matches a group that names a
+// prerelease of the same major.minor.patch, so "^1.2.0" never picks up
+// "1.3.0-beta.1".
+func (cs ConstraintSet) Satisfies(version Version) bool {
+	return cs.Allows(version, false)
+}
+
+// Allows is Satisfies with the prerelease rule optionally lifted, so every
+// prerelease inside the range matches.
+func (cs ConstraintSet) Allows(version Version, includePrerelease bool) bool {
+	for _, group := range cs.Groups {
+		matched := true
+		for _, c := range group {
+			if !c.Satisfies(version) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if version.Prerelease == "" || includePrerelease || namesPrerelease(group, version) {
+			return true
+		}
+	}
+	return false
+}
+
+func namesPrerelease(group []Constraint, version Version) bool {
+	for _, c := range group {
+		if c.Version.Prerelease != "" &&
+			c.Version.Major == version.Major &&
+			c.Version.Minor == version.Minor &&
+			c.Version.Patch == version.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// String prints the set in canonical form, e.g. ">=1.2.0, <1.5.0 || ^2.0.0".
+func (cs ConstraintSet) String() string {
+	groups := make([]string, 0, len(cs.Groups))
+	for _, group := range cs.Groups {
+		if len(group) == 0 {
+			groups = append(groups, "*")
+			continue
+		}
+		parts := make([]string, 0, len(group))
+		for _, c := range group {
+			parts = append(parts, c.String())
+		}
+		groups = append(groups, strings.Join(parts, ", "))
+	}
+	return strings.Join(groups, " || ")
+}
+
+// String prints a single constraint, e.g. "^1.2.0" or "=1.0.0".
+func (c Constraint) String() string {
+	return c.Operator + c.Version.String()
 }
 
 
 func (c Constraint) Satisfies(version Version) bool {
 	switch c.Operator {
 	case "^":
-		
+		// The left-most non-zero component is the one that may not change:
+		// ^1.2.3 means <2.0.0, ^0.2.3 means <0.3.0 and ^0.0.3 means <0.0.4.
 		if version.Major != c.Version.Major {
 			return false
 		}
+		if c.Version.Major == 0 {
+			if version.Minor != c.Version.Minor {
+				return false
+			}
+			if c.Version.Minor == 0 && version.Patch != c.Version.Patch {
+				return false
+			}
+		}
 		return version.Compare(c.Version) >= 0
 		
 	case "~":
@@ -177,17 +403,19 @@ func (c Constraint) Satisfies(version Version) bool {
 }
 
 
-func FindBestMatch(constraint Constraint, versions []Version) (*Version, error) {
+// FindBestMatch returns the highest version satisfying constraint. Prereleases
+// are skipped unless includePrerelease is set.
+func FindBestMatch(constraint ConstraintSet, versions []Version, includePrerelease bool) (*Version, error) {
 	var candidates []Version
 	
 	for _, version := range versions {
-		if constraint.Satisfies(version) {
+		if constraint.Allows(version, includePrerelease) {
 			candidates = append(candidates, version)
 		}
 	}
 	
 	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no version satisfies constraint %s%s", constraint.Operator, constraint.Version)
+		return nil, fmt.Errorf("no version satisfies constraint %s", constraint)
 	}
 	
 	
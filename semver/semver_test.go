@@ -0,0 +1,201 @@
+package semver
+
+import "testing"
+
+func mustVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q): %v", s, err)
+	}
+	return v
+}
+
+func TestParseVersion(t *testing.T) {
+	v := mustVersion(t, "v1.2.3-beta.1+build.5")
+	if v.Major != 1 || v.Minor != 2 || v.Patch != 3 || v.Prerelease != "beta.1" || v.Build != "build.5" {
+		t.Errorf("unexpected version %+v", v)
+	}
+	if got := v.String(); got != "1.2.3-beta.1+build.5" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, s := range []string{"1.2", "1.2.3.4", "1.2.x", "a.b.c", ""} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("ParseVersion(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestComparePrecedence(t *testing.T) {
+	// In ascending order, from the semver 2.0 specification.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+		"10.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, b := mustVersion(t, ordered[i]), mustVersion(t, ordered[j])
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := a.Compare(b); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	if got := mustVersion(t, "1.0.0+a").Compare(mustVersion(t, "1.0.0+b")); got != 0 {
+		t.Errorf("build metadata changed the precedence: %d", got)
+	}
+}
+
+func TestConstraintAllows(t *testing.T) {
+	tests := []struct {
+		constraint        string
+		version           string
+		includePrerelease bool
+		want              bool
+	}{
+		{"^1.2.3", "1.9.0", false, true},
+		{"^1.2.3", "1.2.2", false, false},
+		{"^1.2.3", "2.0.0", false, false},
+		{"^1.2.3", "2.0.0-alpha", true, false},
+		{"^1.2.3", "1.3.0-beta.1", false, false},
+		{"^1.2.3", "1.3.0-beta.1", true, true},
+		{"^1.2.3-beta.1", "1.2.3-beta.2", false, true},
+		{"^0.2.3", "0.2.9", false, true},
+		{"^0.2.3", "0.3.0", false, false},
+		{"^0.0.3", "0.0.4", false, false},
+		{"^1.2", "1.2.0", false, true},
+		{"^1.2", "2.0.0-alpha", true, false},
+		{"^1", "1.9.9", true, true},
+		{"^1", "2.0.0-alpha", true, false},
+		{"^0.0", "0.0.9", false, true},
+		{"^0.0", "0.1.0-alpha", true, false},
+		{"~1.2.3", "1.2.9", false, true},
+		{"~1.2.3", "1.3.0", false, false},
+		{"~1.2.3", "1.3.0-alpha", true, false},
+		{"~1.2", "1.2.5", false, true},
+		{"~1.2", "1.3.0-alpha", true, false},
+		{"~1", "1.9.0", false, true},
+		{"~1", "2.0.0-alpha", true, false},
+		{"1.x", "1.4.0", false, true},
+		{"1.x", "1.4.0-rc.1", true, true},
+		{"1.x", "2.0.0", false, false},
+		{"1.x", "2.0.0-alpha", true, false},
+		{"1.2.*", "1.2.7", false, true},
+		{"1.2.*", "1.3.0-alpha", true, false},
+		{"1", "1.0.0", false, true},
+		{"*", "3.1.4", false, true},
+		{"*", "3.1.4-alpha", false, false},
+		{"*", "3.1.4-alpha", true, true},
+		{"<=1.4", "1.4.9", false, true},
+		{"<=1.4", "1.5.0-alpha", true, false},
+		{"<2", "1.9.9", false, true},
+		{"<2", "2.0.0-alpha", true, false},
+		{">1", "2.0.0", false, true},
+		{">1", "1.9.9", false, false},
+		{">=1.2.0, <1.5.0", "1.4.9", false, true},
+		{">=1.2.0 <1.5.0", "1.5.0", false, false},
+		{"1.2.0 - 1.4", "1.4.9", false, true},
+		{"1.2.0 - 1.4", "1.5.0-alpha", true, false},
+		{"1.2.0 - 1.4.0", "1.4.0", false, true},
+		{"1.2.0 - 1.4.0", "1.4.1", false, false},
+		{"=1.0.0", "1.0.0", false, true},
+		{"1.0.0", "1.0.1", false, false},
+		{"^1.0 || ^3.0", "3.2.0", false, true},
+		{"^1.0 || ^3.0", "2.2.0", false, false},
+	}
+
+	for _, tt := range tests {
+		cs, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		if got := cs.Allows(mustVersion(t, tt.version), tt.includePrerelease); got != tt.want {
+			t.Errorf("%q.Allows(%s, %t) = %t, want %t (%s)", tt.constraint, tt.version, tt.includePrerelease, got, tt.want, cs)
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, constraint := range []string{"", "||", "^", "1.2.3.4", ">*", "<x", "abc"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("ParseConstraint(%q) succeeded, want an error", constraint)
+		}
+	}
+}
+
+func TestConstraintSetString(t *testing.T) {
+	tests := map[string]string{
+		"^1.2.3":           "^1.2.3",
+		">=1.2.0,<1.5.0":   ">=1.2.0, <1.5.0",
+		"1.x":              ">=1.0.0, <2.0.0-0",
+		"* || =1.0.0":      "* || =1.0.0",
+		"~1.2.0 || ^2.0.0": "~1.2.0 || ^2.0.0",
+	}
+	for constraint, want := range tests {
+		cs, err := ParseConstraint(constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", constraint, err)
+		}
+		if got := cs.String(); got != want {
+			t.Errorf("ParseConstraint(%q).String() = %q, want %q", constraint, got, want)
+		}
+	}
+}
+
+func TestFindBestMatch(t *testing.T) {
+	var versions []Version
+	for _, s := range []string{"1.0.0", "1.4.2", "1.5.0-beta.1", "2.0.0-alpha", "2.1.0"} {
+		versions = append(versions, mustVersion(t, s))
+	}
+
+	tests := []struct {
+		constraint        string
+		includePrerelease bool
+		want              string
+	}{
+		{"^1.0", false, "1.4.2"},
+		{"^1.0", true, "1.5.0-beta.1"},
+		{"1.x", true, "1.5.0-beta.1"},
+		{"*", false, "2.1.0"},
+		{"<2", true, "1.5.0-beta.1"},
+	}
+	for _, tt := range tests {
+		cs, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", tt.constraint, err)
+		}
+		best, err := FindBestMatch(cs, versions, tt.includePrerelease)
+		if err != nil {
+			t.Errorf("FindBestMatch(%q): %v", tt.constraint, err)
+			continue
+		}
+		if best.String() != tt.want {
+			t.Errorf("FindBestMatch(%q, %t) = %s, want %s", tt.constraint, tt.includePrerelease, best, tt.want)
+		}
+	}
+
+	cs, _ := ParseConstraint("^3.0")
+	if _, err := FindBestMatch(cs, versions, true); err == nil {
+		t.Errorf("FindBestMatch(^3.0) succeeded, want an error")
+	}
+}
@@ -0,0 +1,287 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+)
+
+// ArchiveSource is a single tarball or zip file downloaded over HTTP(S).
+// An archive has exactly one version, taken from its file name (for example
+// "parser-1.2.0.tar.gz" or ".../v1.2.0.zip").
+type ArchiveSource struct {
+	url     string
+	version *semver.Version
+}
+
+var archiveVersionRegex = regexp.MustCompile(`v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)$`)
+
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+func NewArchiveSource(archiveURL string) (*ArchiveSource, error) {
+	u, err := url.Parse(archiveURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("archive must be an http or https URL: %s", archiveURL)
+	}
+
+	if archiveFormat(u.Path) == "" {
+		return nil, fmt.Errorf("unsupported archive format: %s (expected %s)", archiveURL, strings.Join(archiveExtensions, ", "))
+	}
+
+	s := &ArchiveSource{url: archiveURL}
+
+	name := path.Base(u.Path)
+	name = strings.TrimSuffix(name, archiveFormat(u.Path))
+	if matches := archiveVersionRegex.FindStringSubmatch(name); matches != nil {
+		if v, err := semver.ParseVersion(matches[1]); err == nil {
+			s.version = &v
+		}
+	}
+
+	return s, nil
+}
+
+func (s *ArchiveSource) Describe() string {
+	return "archive+" + s.url
+}
+
+func (s *ArchiveSource) Key() string {
+	sum := sha256.Sum256([]byte(s.url))
+	return "archive/" + hex.EncodeToString(sum[:8])
+}
+
+// ZigURL returns the archive URL. An archive has no commits, and Zig strips
+// a single top-level directory from it like yuki does.
+func (s *ArchiveSource) ZigURL(commit string) string {
+	return s.url
+}
+
+func (s *ArchiveSource) ListVersions() ([]Version, error) {
+	if s.version == nil {
+		return nil, nil
+	}
+	return []Version{{Version: *s.version}}, nil
+}
+
+func (s *ArchiveSource) ResolveRef(dep manifest.Dependency) (Revision, error) {
+	if dep.Rev != "" || dep.Tag != "" || dep.Branch != "" {
+		return Revision{}, fmt.Errorf("archive dependencies cannot use rev, tag or branch")
+	}
+
+	version := "archive"
+	if s.version != nil {
+		version = s.version.String()
+	} else if v, err := semver.ParseVersion(strings.TrimPrefix(dep.Version, "=")); err == nil {
+		version = v.String()
+	}
+
+	return Revision{Ref: version, Version: version}, nil
+}
+
+func (s *ArchiveSource) Fetch(rev Revision, dir string) (string, error) {
+	logger.Debug("Downloading %s to %s", s.url, dir)
+
+	tmp, err := os.CreateTemp("", "yuki-archive-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(s.url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to download %s: HTTP %d", s.url, resp.StatusCode)
+	}
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", s.url, err)
+	}
+
+	u, _ := url.Parse(s.url)
+	if err := extractArchive(tmp.Name(), archiveFormat(u.Path), dir); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to extract %s: %w", s.url, err)
+	}
+
+	return "", nil
+}
+
+func archiveFormat(name string) string {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// extractArchive unpacks an archive into dir. When every entry lives under a
+// single top-level directory, as in GitHub and GitLab tarballs, that
+// directory becomes dir.
+func extractArchive(file, format, dir string) error {
+	staging := dir + ".extract"
+	os.RemoveAll(staging)
+	defer os.RemoveAll(staging)
+
+	var err error
+	if format == ".zip" {
+		err = extractZip(file, staging)
+	} else {
+		err = extractTar(file, format != ".tar", staging)
+	}
+	if err != nil {
+		return err
+	}
+
+	root := staging
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(staging, entries[0].Name())
+	}
+
+	return os.Rename(root, dir)
+}
+
+func extractTar(file string, gzipped bool, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := archiveEntryPath(dir, header.Name)
+		if target == "" {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, os.FileMode(header.Mode).Perm(), tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			linked := filepath.Join(filepath.Dir(target), header.Linkname)
+			if filepath.IsAbs(header.Linkname) || !strings.HasPrefix(linked, filepath.Clean(dir)+string(filepath.Separator)) {
+				return fmt.Errorf("archive entry '%s' links outside the package", header.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(file, dir string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		target := archiveEntryPath(dir, entry.Name)
+		if target == "" {
+			continue
+		}
+
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(target, entry.Mode().Perm(), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveEntryPath returns where an archive entry is extracted to. Entry
+// names are cleaned as if rooted at dir, so "../" cannot leave it.
+func archiveEntryPath(dir, name string) string {
+	cleaned := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if cleaned == "/" {
+		return ""
+	}
+	return filepath.Join(dir, filepath.FromSlash(cleaned))
+}
+
+func writeArchiveFile(target string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,366 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+	"yuki_zpm.org/utils"
+)
+
+// GitSource is any git repository reachable with the git command line.
+// Versions are the repository's semver tags.
+type GitSource struct {
+	url string
+}
+
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+func NewGitSource(repoURL string) (*GitSource, error) {
+	if strings.TrimSpace(repoURL) == "" {
+		return nil, fmt.Errorf("git URL is empty")
+	}
+	return &GitSource{url: repoURL}, nil
+}
+
+func (s *GitSource) Describe() string {
+	return "git+" + s.url
+}
+
+func (s *GitSource) Key() string {
+	host, repoPath := splitGitURL(s.url)
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+
+	var parts []string
+	for _, part := range append([]string{"git", host}, strings.Split(repoPath, "/")...) {
+		if part = sanitizeKeyPart(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *GitSource) ListVersions() ([]Version, error) {
+	tags, err := s.tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, tag := range tags {
+		if v, err := semver.ParseVersion(tag); err == nil {
+			versions = append(versions, Version{Version: v, Tag: tag})
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *GitSource) ResolveRef(dep manifest.Dependency) (Revision, error) {
+	return resolveGitRef(s, dep, s.ListVersions, func() (string, error) {
+		versions, err := s.ListVersions()
+		if err != nil {
+			return "", err
+		}
+		latest, found := LatestStable(versions)
+		if !found {
+			return "", fmt.Errorf("no released versions found in %s", s.url)
+		}
+		return latest.Tag, nil
+	})
+}
+
+func (s *GitSource) Fetch(rev Revision, dir string) (string, error) {
+	logger.Debug("Cloning %s@%s to %s", s.url, rev.Ref, dir)
+
+	ref := rev.Ref
+	if ref == "" {
+		ref = rev.Commit
+	}
+
+	if len(ref) == 40 && utils.IsHexString(ref) {
+		if err := fetchCommit(s.url, ref, dir); err != nil {
+			logger.Debug("Fetching commit %s directly failed, trying full clone: %v", ref, err)
+
+			os.RemoveAll(dir)
+			if err := fullClone(s.url, ref, dir); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		cmd := exec.Command("git", "clone", "--depth=1", "--branch", ref, s.url, dir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if !strings.Contains(string(output), "does not exist") && !strings.Contains(string(output), "not found") {
+				return "", fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, output)
+			}
+
+			logger.Debug("Shallow clone failed, trying full clone: %s", string(output))
+
+			os.RemoveAll(dir)
+			if err := fullClone(s.url, ref, dir); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read checked out commit: %w", err)
+	}
+
+	os.RemoveAll(filepath.Join(dir, ".git"))
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// tags lists the tag names of the remote repository.
+func (s *GitSource) tags() ([]string, error) {
+	cmd := exec.Command("git", "ls-remote", "--tags", "--refs", s.url)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", s.url, err)
+	}
+
+	var tags []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		tags = append(tags, strings.TrimPrefix(fields[1], "refs/tags/"))
+	}
+
+	return tags, nil
+}
+
+func (s *GitSource) tagExists(tag string) (bool, error) {
+	cmd := exec.Command("git", "ls-remote", "--tags", s.url, tag)
+	output, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(string(output), tag), nil
+}
+
+// head returns the default branch of the remote repository and the commit it
+// points to.
+func (s *GitSource) head() (string, string, error) {
+	cmd := exec.Command("git", "ls-remote", "--symref", s.url, "HEAD")
+	if output, err := cmd.Output(); err == nil {
+		branch, commit := "", ""
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 3 && fields[0] == "ref:":
+				branch = strings.TrimPrefix(fields[1], "refs/heads/")
+			case len(fields) == 2 && fields[1] == "HEAD":
+				commit = fields[0]
+			}
+		}
+		if branch != "" && commit != "" {
+			return branch, commit, nil
+		}
+	}
+
+	for _, branch := range []string{"main", "master"} {
+		cmd := exec.Command("git", "ls-remote", s.url, "refs/heads/"+branch)
+		output, err := cmd.Output()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get latest commit: %w", err)
+		}
+		if parts := strings.Fields(string(output)); len(parts) > 0 {
+			return branch, parts[0], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no commit found on the default branch of %s", s.url)
+}
+
+// BranchCommit returns the commit a branch of the remote repository points
+// to. An empty branch means the default branch.
+func (s *GitSource) BranchCommit(branch string) (string, error) {
+	if branch == "" {
+		_, commit, err := s.head()
+		return commit, err
+	}
+
+	cmd := exec.Command("git", "ls-remote", "--heads", s.url, "refs/heads/"+branch)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list branches of %s: %w", s.url, err)
+	}
+	if fields := strings.Fields(string(output)); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", fmt.Errorf("branch '%s' does not exist in %s", branch, s.url)
+}
+
+// ZigURL pins the repository to commit, as `zig fetch git+<url>#<commit>`
+// does. Zig only understands URLs, so scp-like remotes such as
+// git@host:owner/repo.git are written as git+ssh://git@host/owner/repo.git.
+func (s *GitSource) ZigURL(commit string) string {
+	repoURL := s.url
+	if u, err := url.Parse(repoURL); err != nil || u.Scheme == "" || u.Host == "" {
+		matches := scpLikeURL.FindStringSubmatch(repoURL)
+		if matches != nil && (strings.Contains(repoURL, "@") || strings.Contains(matches[1], ".")) {
+			userHost := repoURL[:len(repoURL)-len(matches[2])-1]
+			repoURL = "ssh://" + userHost + "/" + strings.TrimPrefix(matches[2], "/")
+		}
+	}
+	return "git+" + repoURL + "#" + commit
+}
+
+// resolveGitRef implements ResolveRef for git based sources. versions lists
+// the published versions and latest returns the tag of the latest release.
+func resolveGitRef(s *GitSource, dep manifest.Dependency, versions func() ([]Version, error), latest func() (string, error)) (Revision, error) {
+	if dep.Rev != "" {
+		return Revision{Ref: dep.Rev, Version: dep.Rev, Commit: dep.Rev}, nil
+	}
+	if dep.Tag != "" {
+		return Revision{Ref: dep.Tag, Version: dep.Tag}, nil
+	}
+	if dep.Branch != "" {
+		return Revision{Ref: dep.Branch, Version: dep.Branch}, nil
+	}
+
+	if dep.UseLatestCommit {
+		branch, commit, err := s.head()
+		if err != nil {
+			return Revision{}, err
+		}
+		logger.Debug("Latest commit SHA: %s", commit)
+		return Revision{Ref: commit, Version: branch, Commit: commit}, nil
+	}
+
+	if dep.Version != "" && dep.Version != "latest" {
+		version := strings.TrimPrefix(dep.Version, "=")
+		var possibleTags []string
+
+		if _, err := semver.ParseVersion(version); err == nil {
+			possibleTags = []string{
+				version,       // example 0.10.0
+				"v" + version, // example v0.10.0
+			}
+		} else {
+			available, err := versions()
+			if err != nil {
+				return Revision{}, fmt.Errorf("failed to get available versions: %w", err)
+			}
+			if len(available) == 0 {
+				return Revision{}, fmt.Errorf("no semantic versions found in %s", s.url)
+			}
+			best, err := bestMatch(dep, available)
+			if err != nil {
+				return Revision{}, fmt.Errorf("no version satisfies constraint '%s': %w", dep.Version, err)
+			}
+			if best.Tag != "" {
+				return Revision{Ref: best.Tag, Version: best.String()}, nil
+			}
+			version = best.String()
+			possibleTags = []string{version, "v" + version}
+		}
+
+		for _, tag := range possibleTags {
+			exists, err := s.tagExists(tag)
+			if err != nil {
+				logger.Debug("Failed to check tag %s: %v", tag, err)
+				continue
+			}
+			if exists {
+				return Revision{Ref: tag, Version: version}, nil
+			}
+		}
+
+		return Revision{}, fmt.Errorf("no matching tag found for version %s (tried: %s)", dep.Version, strings.Join(possibleTags, ", "))
+	}
+
+	tag, err := latest()
+	if err == nil && tag != "" {
+		logger.Debug("Using latest release tag: %s", tag)
+		return Revision{Ref: tag, Version: tag}, nil
+	}
+	if dep.Version == "latest" {
+		return Revision{}, fmt.Errorf("no releases found in %s", s.url)
+	}
+
+	logger.Debug("No releases found in %s, using the default branch", s.url)
+	branch, commit, err := s.head()
+	if err != nil {
+		return Revision{}, err
+	}
+	return Revision{Ref: commit, Version: branch, Commit: commit}, nil
+}
+
+// fetchCommit downloads a single commit without the rest of the history.
+func fetchCommit(repoURL, commit, targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", repoURL},
+		{"fetch", "--quiet", "--depth=1", "origin", commit},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = targetDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w\nOutput: %s", args[0], err, output)
+		}
+	}
+	return nil
+}
+
+func fullClone(repoURL, ref, targetDir string) error {
+	cmd := exec.Command("git", "clone", "--quiet", repoURL, targetDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, output)
+	}
+
+	cmd = exec.Command("git", "checkout", "--quiet", ref)
+	cmd.Dir = targetDir
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to checkout ref '%s': %w", ref, err)
+	}
+	return nil
+}
+
+// splitGitURL returns the host and repository path of a git URL. Local paths
+// have an empty host.
+func splitGitURL(repoURL string) (string, string) {
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Host, u.Path
+	}
+	matches := scpLikeURL.FindStringSubmatch(repoURL)
+	if matches != nil && (strings.Contains(repoURL, "@") || strings.Contains(matches[1], ".")) {
+		return matches[1], matches[2]
+	}
+	return "", strings.TrimPrefix(repoURL, "file://")
+}
+
+func sanitizeKeyPart(part string) string {
+	if part == "." || part == ".." {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, part)
+}
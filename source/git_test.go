@@ -0,0 +1,46 @@
+package source
+
+import "testing"
+
+func TestGitZigURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/owner/repo.git", "git+https://example.com/owner/repo.git#abc"},
+		{"ssh://git@example.com/owner/repo.git", "git+ssh://git@example.com/owner/repo.git#abc"},
+		{"git@example.com:owner/repo.git", "git+ssh://git@example.com/owner/repo.git#abc"},
+		{"git@example.com:/srv/repo.git", "git+ssh://git@example.com/srv/repo.git#abc"},
+		{"example.com:owner/repo", "git+ssh://example.com/owner/repo#abc"},
+	}
+
+	for _, tt := range tests {
+		src, err := NewGitSource(tt.url)
+		if err != nil {
+			t.Fatalf("NewGitSource(%q): %v", tt.url, err)
+		}
+		if got := src.ZigURL("abc"); got != tt.want {
+			t.Errorf("ZigURL of %q = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSplitGitURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		host, path string
+	}{
+		{"https://example.com/owner/repo.git", "example.com", "/owner/repo.git"},
+		{"git@example.com:owner/repo.git", "example.com", "owner/repo.git"},
+		{"file:///tmp/repo", "", "/tmp/repo"},
+		{"/tmp/repo", "", "/tmp/repo"},
+		{"../repo", "", "../repo"},
+	}
+
+	for _, tt := range tests {
+		host, path := splitGitURL(tt.url)
+		if host != tt.host || path != tt.path {
+			t.Errorf("splitGitURL(%q) = %q, %q, want %q, %q", tt.url, host, path, tt.host, tt.path)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package source
+
+import (
+	"fmt"
+
+	"yuki_zpm.org/github"
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+)
+
+// GitHubSource is a repository on github.com. It is cloned like any other git
+// repository, but versions and releases come from the GitHub API so drafts
+// and prereleases can be told apart.
+type GitHubSource struct {
+	*GitSource
+	Owner  string
+	Repo   string
+	origin string
+	client *github.Client
+}
+
+func NewGitHubSource(repoURL string) (*GitHubSource, error) {
+	owner, repo, err := github.ParseRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHubSource{
+		GitSource: &GitSource{url: fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)},
+		Owner:     owner,
+		Repo:      repo,
+		origin:    repoURL,
+		client:    github.NewClient(),
+	}, nil
+}
+
+// Describe returns the URL as written in the manifest, which is what
+// yuki.lock has always recorded for GitHub packages. The same repository can
+// be written in several ways, so sources are compared with Same or Identity
+// rather than by their description.
+func (s *GitHubSource) Describe() string {
+	return s.origin
+}
+
+func (s *GitHubSource) Key() string {
+	return s.Owner + "/" + s.Repo
+}
+
+func (s *GitHubSource) ListVersions() ([]Version, error) {
+	tags, err := s.client.GetTags(s.Owner, s.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	unstable, err := s.client.GetUnstableVersions(s.Owner, s.Repo)
+	if err != nil {
+		logger.Debug("Could not list releases of %s/%s: %v", s.Owner, s.Repo, err)
+	}
+
+	var versions []Version
+	for _, tag := range tags {
+		if v, err := semver.ParseVersion(tag); err == nil {
+			versions = append(versions, Version{Version: v, Tag: tag, Unstable: unstable[v.String()]})
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *GitHubSource) ResolveRef(dep manifest.Dependency) (Revision, error) {
+	return resolveGitRef(s.GitSource, dep, s.ListVersions, func() (string, error) {
+		release, err := s.client.GetLatestRelease(s.Owner, s.Repo)
+		if err != nil {
+			return "", err
+		}
+		return release.TagName, nil
+	})
+}
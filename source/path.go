@@ -0,0 +1,147 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+)
+
+// Local is implemented by sources that are used in place instead of being
+// fetched into the cache. They never need network access.
+type Local interface {
+	Dir() string
+}
+
+// PathSource is a package in a directory next to the project, typically
+// another library of the same repository. Its path is relative to the
+// project root, which is what yuki.lock records.
+type PathSource struct {
+	path string
+	dir  string
+}
+
+func NewPathSource(path, root string) (*PathSource, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+
+	dir := path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, path)
+	}
+
+	return &PathSource{path: filepath.ToSlash(filepath.Clean(path)), dir: dir}, nil
+}
+
+func (s *PathSource) Describe() string {
+	return "path+" + s.path
+}
+
+func (s *PathSource) Key() string {
+	var parts []string
+	for _, part := range append([]string{"path"}, strings.Split(s.path, "/")...) {
+		if part = sanitizeKeyPart(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *PathSource) Dir() string {
+	return s.dir
+}
+
+// ListVersions returns the version declared in the package's yuki.toml, if
+// it has one.
+func (s *PathSource) ListVersions() ([]Version, error) {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("path dependency '%s' does not exist", s.path)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path dependency '%s' is not a directory", s.path)
+	}
+
+	if !manifest.Exists(s.dir) {
+		return nil, nil
+	}
+
+	m, err := manifest.Load(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest of '%s': %w", s.path, err)
+	}
+
+	v, err := semver.ParseVersion(m.Package.Version)
+	if err != nil {
+		return nil, nil
+	}
+	return []Version{{Version: v}}, nil
+}
+
+func (s *PathSource) ResolveRef(dep manifest.Dependency) (Revision, error) {
+	if dep.Rev != "" || dep.Tag != "" || dep.Branch != "" {
+		return Revision{}, fmt.Errorf("path dependencies cannot use rev, tag or branch")
+	}
+
+	versions, err := s.ListVersions()
+	if err != nil {
+		return Revision{}, err
+	}
+
+	version := "0.0.0"
+	if len(versions) > 0 {
+		version = versions[0].String()
+	}
+	return Revision{Ref: version, Version: version}, nil
+}
+
+// Fetch copies the package into dir. The fetcher uses path packages in place,
+// so this is only needed by callers that want a snapshot.
+func (s *PathSource) Fetch(rev Revision, dir string) (string, error) {
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to copy '%s': %w", s.path, err)
+	}
+	return "", nil
+}
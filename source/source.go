@@ -0,0 +1,262 @@
+package source
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
+)
+
+// Source is a place packages are fetched from. The resolver and fetcher only
+// talk to sources through this interface, so a new kind of backend needs a
+// constructor registered with Register and nothing else.
+type Source interface {
+	// Describe returns the identifier recorded as the package source in
+	// yuki.lock. Two dependencies with the same description are the same
+	// package.
+	Describe() string
+
+	// Key returns a slash separated identifier that is safe to use in cache
+	// keys and cache directory names.
+	Key() string
+
+	// ListVersions returns every semantic version the source publishes.
+	ListVersions() ([]Version, error)
+
+	// ResolveRef turns the version, tag, branch or rev requested by dep into
+	// a concrete revision that can be fetched.
+	ResolveRef(dep manifest.Dependency) (Revision, error)
+
+	// Fetch places the contents of rev in dir, which must not exist yet, and
+	// returns the commit that was checked out if the source has commits.
+	Fetch(rev Revision, dir string) (string, error)
+}
+
+// Branches is implemented by sources with git history, which lets commands
+// compare a locked commit with the current head of a branch.
+type Branches interface {
+	BranchCommit(branch string) (string, error)
+}
+
+// ZigFetchable is implemented by sources Zig's package manager can fetch
+// from itself, which lets yuki write them to build.zig.zon.
+type ZigFetchable interface {
+	// ZigURL returns the .url of a build.zig.zon dependency on the package
+	// at commit, which is ignored by sources without commits.
+	ZigURL(commit string) string
+}
+
+// Version is a published version of a package.
+type Version struct {
+	semver.Version
+
+	// Tag is the name the version was published under, e.g. "v1.2.0".
+	Tag string
+
+	// Unstable is set for versions published as a draft or prerelease.
+	Unstable bool
+}
+
+// Revision is a resolved point in a source's history.
+type Revision struct {
+	// Ref is what Fetch checks out: a tag, branch or commit.
+	Ref string
+
+	// Version is the version recorded for the package.
+	Version string
+
+	// Commit is the commit Ref points to, when it is known before fetching.
+	Commit string
+}
+
+// kind knows how to build one kind of source, either from a manifest entry
+// or from the location recorded after its "<kind>+" prefix in yuki.lock.
+type kind struct {
+	new        func(dep manifest.Dependency) (Source, error)
+	dependency func(location string) manifest.Dependency
+}
+
+var kinds = map[string]kind{}
+
+func init() {
+	Register(manifest.SourceGitHub,
+		func(dep manifest.Dependency) (Source, error) { return NewGitHubSource(dep.Git) },
+		func(location string) manifest.Dependency { return manifest.Dependency{Git: location} })
+	Register(manifest.SourceGit,
+		func(dep manifest.Dependency) (Source, error) { return NewGitSource(dep.Git) },
+		func(location string) manifest.Dependency { return manifest.Dependency{Git: location} })
+	Register(manifest.SourcePath,
+		func(dep manifest.Dependency) (Source, error) { return NewPathSource(dep.Path, dep.BaseDir) },
+		func(location string) manifest.Dependency { return manifest.Dependency{Path: location} })
+	Register(manifest.SourceArchive,
+		func(dep manifest.Dependency) (Source, error) { return NewArchiveSource(dep.Archive) },
+		func(location string) manifest.Dependency { return manifest.Dependency{Archive: location} })
+}
+
+// Register adds a kind of source. Dependencies whose SourceKind returns name
+// are handed to fn, and yuki.lock sources written as "<name>+<location>" are
+// turned back into a dependency with dependency.
+func Register(name string, fn func(dep manifest.Dependency) (Source, error), dependency func(location string) manifest.Dependency) {
+	kinds[name] = kind{new: fn, dependency: dependency}
+}
+
+// New returns the source a dependency is fetched from.
+func New(dep manifest.Dependency) (Source, error) {
+	name := dep.SourceKind()
+	k, exists := kinds[name]
+	if !exists {
+		return nil, fmt.Errorf("unsupported source kind '%s'", name)
+	}
+	return k.new(dep)
+}
+
+// Parse returns the source described by a yuki.lock source string, i.e. the
+// result of Describe. GitHub sources are recorded without a prefix.
+func Parse(description string) (Source, error) {
+	if name, location, found := strings.Cut(description, "+"); found {
+		if k, exists := kinds[name]; exists {
+			return k.new(k.dependency(location))
+		}
+	}
+	return NewGitHubSource(description)
+}
+
+// Matches reports whether description, a yuki.lock source, names the source
+// of dep. Paths, which yuki.lock records relative to the project root, have
+// to be written the same way.
+func Matches(dep manifest.Dependency, description string) bool {
+	if Describe(dep) == description {
+		return true
+	}
+	if dep.Path != "" {
+		return false
+	}
+
+	locked := manifest.Dependency{Git: description}
+	if name, location, found := strings.Cut(description, "+"); found {
+		if k, exists := kinds[name]; exists {
+			locked = k.dependency(location)
+		}
+	}
+	return locked.Path == "" && Same(dep, locked)
+}
+
+// Describe returns the yuki.lock source of dep, or an empty string if dep
+// does not name a valid source.
+func Describe(dep manifest.Dependency) string {
+	src, err := New(dep)
+	if err != nil {
+		return ""
+	}
+	return src.Describe()
+}
+
+// Identity returns the location of dep without scheme, user or ".git"
+// suffix, e.g. "github.com/owner/repo", which is how [patch] tables name the
+// packages they replace. Path dependencies have no identity.
+func Identity(dep manifest.Dependency) string {
+	switch {
+	case dep.Git != "" && dep.SourceKind() == manifest.SourceGitHub && !strings.Contains(dep.Git, "://") && !strings.Contains(dep.Git, "@"):
+		// The "owner/repo" shorthand.
+		return NormalizeIdentity("github.com/" + dep.Git)
+	case dep.Git != "":
+		return NormalizeIdentity(dep.Git)
+	case dep.Archive != "":
+		return NormalizeIdentity(dep.Archive)
+	default:
+		return ""
+	}
+}
+
+// Same reports whether a and b name the same source, however their
+// locations are spelled: "owner/repo" and "https://github.com/owner/repo.git"
+// are the same repository, and two paths are the same when they lead to the
+// same directory.
+func Same(a, b manifest.Dependency) bool {
+	if a.Path != "" || b.Path != "" {
+		if a.Path == "" || b.Path == "" {
+			return false
+		}
+		pathA, errA := NewPathSource(a.Path, a.BaseDir)
+		pathB, errB := NewPathSource(b.Path, b.BaseDir)
+		return errA == nil && errB == nil && filepath.Clean(pathA.Dir()) == filepath.Clean(pathB.Dir())
+	}
+	if (a.Archive != "") != (b.Archive != "") {
+		return false
+	}
+	return Identity(a) == Identity(b)
+}
+
+// NormalizeIdentity turns a repository URL or a [patch] key into the form
+// returned by Identity.
+func NormalizeIdentity(location string) string {
+	host, path := splitGitURL(strings.TrimSpace(location))
+	if host == "" && !strings.Contains(location, "://") && !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, ".") {
+		// "github.com/owner/repo" has no scheme and is not a local path.
+		host, path, _ = strings.Cut(path, "/")
+	}
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+
+	path = strings.TrimSuffix(strings.TrimRight(path, "/"), ".git")
+	if host == "" {
+		return path
+	}
+	return strings.ToLower(host) + "/" + strings.TrimLeft(path, "/")
+}
+
+// SortVersions orders versions from newest to oldest.
+func SortVersions(versions []Version) {
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j].Version) > 0
+	})
+}
+
+// bestMatch picks the newest version satisfying dep.Version. Unstable
+// versions are only considered when the dependency opts into prereleases.
+func bestMatch(dep manifest.Dependency, versions []Version) (Version, error) {
+	constraint, err := semver.ParseConstraint(dep.Version)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid version constraint '%s': %w", dep.Version, err)
+	}
+
+	var plain []semver.Version
+	for _, v := range versions {
+		if v.Unstable && !dep.Prerelease {
+			continue
+		}
+		plain = append(plain, v.Version)
+	}
+
+	best, err := semver.FindBestMatch(constraint, plain, dep.Prerelease)
+	if err != nil {
+		return Version{}, err
+	}
+
+	for _, v := range versions {
+		if v.Compare(*best) == 0 {
+			return v, nil
+		}
+	}
+	return Version{Version: *best}, nil
+}
+
+// LatestStable returns the newest version that is not a prerelease.
+func LatestStable(versions []Version) (Version, bool) {
+	var latest Version
+	found := false
+	for _, v := range versions {
+		if v.Unstable || v.Prerelease != "" {
+			continue
+		}
+		if !found || v.Compare(latest.Version) > 0 {
+			latest = v
+			found = true
+		}
+	}
+	return latest, found
+}
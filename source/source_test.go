@@ -0,0 +1,78 @@
+package source
+
+import (
+	"testing"
+
+	"yuki_zpm.org/manifest"
+)
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		dep  manifest.Dependency
+		want string
+	}{
+		{manifest.Dependency{Git: "owner/repo"}, "github.com/owner/repo"},
+		{manifest.Dependency{Git: "https://github.com/owner/repo"}, "github.com/owner/repo"},
+		{manifest.Dependency{Git: "https://github.com/owner/repo.git"}, "github.com/owner/repo"},
+		{manifest.Dependency{Git: "git@github.com:owner/repo.git"}, "github.com/owner/repo"},
+		{manifest.Dependency{Git: "https://GitLab.com/group/repo.git/"}, "gitlab.com/group/repo"},
+		{manifest.Dependency{Git: "ssh://git@example.com/repo"}, "example.com/repo"},
+		{manifest.Dependency{Git: "/tmp/x"}, "/tmp/x"},
+		{manifest.Dependency{Git: "file:///tmp/x"}, "/tmp/x"},
+		{manifest.Dependency{Archive: "https://example.com/pkg-1.0.tar.gz"}, "example.com/pkg-1.0.tar.gz"},
+		{manifest.Dependency{Path: "../lib"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Identity(tt.dep); got != tt.want {
+			t.Errorf("Identity(%+v) = %q, want %q", tt.dep, got, tt.want)
+		}
+	}
+}
+
+func TestSame(t *testing.T) {
+	tests := []struct {
+		a, b manifest.Dependency
+		want bool
+	}{
+		{manifest.Dependency{Git: "owner/repo"}, manifest.Dependency{Git: "https://github.com/owner/repo.git"}, true},
+		{manifest.Dependency{Git: "/tmp/x"}, manifest.Dependency{Git: "file:///tmp/x"}, true},
+		{manifest.Dependency{Git: "owner/repo"}, manifest.Dependency{Git: "owner/other"}, false},
+		{manifest.Dependency{Git: "https://example.com/a.tar.gz"}, manifest.Dependency{Archive: "https://example.com/a.tar.gz"}, false},
+		{manifest.Dependency{Path: "lib", BaseDir: "/work"}, manifest.Dependency{Path: "../lib", BaseDir: "/work/app"}, true},
+		{manifest.Dependency{Path: "lib", BaseDir: "/work"}, manifest.Dependency{Path: "lib", BaseDir: "/work/app"}, false},
+		{manifest.Dependency{Path: "/tmp/x"}, manifest.Dependency{Git: "/tmp/x"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Same(tt.a, tt.b); got != tt.want {
+			t.Errorf("Same(%+v, %+v) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+		if got := Same(tt.b, tt.a); got != tt.want {
+			t.Errorf("Same(%+v, %+v) = %t, want %t", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		dep         manifest.Dependency
+		description string
+		want        bool
+	}{
+		{manifest.Dependency{Git: "owner/repo"}, "owner/repo", true},
+		{manifest.Dependency{Git: "https://github.com/owner/repo"}, "owner/repo", true},
+		{manifest.Dependency{Git: "/tmp/x"}, "git+file:///tmp/x", true},
+		{manifest.Dependency{Git: "/tmp/x"}, "git+/tmp/y", false},
+		{manifest.Dependency{Path: "lib"}, "path+lib", true},
+		{manifest.Dependency{Path: "./lib"}, "path+lib", true},
+		{manifest.Dependency{Path: "lib"}, "path+other", false},
+		{manifest.Dependency{Archive: "https://example.com/a.tgz"}, "archive+https://example.com/a.tgz", true},
+	}
+
+	for _, tt := range tests {
+		if got := Matches(tt.dep, tt.description); got != tt.want {
+			t.Errorf("Matches(%+v, %q) = %t, want %t", tt.dep, tt.description, got, tt.want)
+		}
+	}
+}
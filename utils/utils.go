@@ -5,10 +5,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"yuki_zpm.org/manifest"
+	"yuki_zpm.org/semver"
 )
 
 func IsHexString(s string) bool {
@@ -20,9 +20,11 @@ func IsHexString(s string) bool {
 	return true
 }
 
-func GenerateCacheKey(owner, repo string, dep manifest.Dependency) string {
+// GenerateCacheKey identifies a fetched dependency in the cache. sourceKey is
+// the source's slash separated Key, e.g. "owner/repo" for GitHub packages.
+func GenerateCacheKey(sourceKey string, dep manifest.Dependency) string {
 	var parts []string
-	parts = append(parts, owner, repo)
+	parts = append(parts, strings.Split(sourceKey, "/")...)
 	
 	if dep.Rev != "" {
 		parts = append(parts, "rev", dep.Rev)
@@ -130,6 +132,10 @@ pub fn build(b: *std.Build) void {
 	return nil
 }
 
+// DetectZigVersion returns the output of `zig version`, e.g. "0.13.0" or
+// "0.14.0-dev.1911+3bf89f55c". Dev builds are kept as they are, since they
+// come before the release they lead up to. It returns an empty string if zig
+// is not installed.
 func DetectZigVersion() string {
 	cmd := exec.Command("zig", "version")
 	output, err := cmd.Output()
@@ -138,23 +144,51 @@ func DetectZigVersion() string {
 	}
 
 	version := strings.TrimSpace(string(output))
-	
-	// Handle different Zig version formats
-	// Examples: "0.12.0", "0.13.0-dev.123+abc123", "0.11.0"
-	
-	// Extract base version (major.minor.patch)
-	re := regexp.MustCompile(`^(\d+\.\d+\.\d+)`)
-	matches := re.FindStringSubmatch(version)
-	if len(matches) > 1 {
-		return matches[1]
+	if _, err := semver.ParseVersion(version); err != nil {
+		return ""
+	}
+	return version
+}
+
+// ZigVersionSatisfies reports whether zigVersion fits the zig_version a
+// package declares. A plain version such as "0.13.0" is a minimum; anything
+// else is a version requirement, e.g. ">=0.12.0, <0.14.0" or "~0.13".
+func ZigVersionSatisfies(required, zigVersion string) (bool, error) {
+	constraint, err := ParseZigRequirement(required)
+	if err != nil {
+		return false, err
 	}
 
-	devRe := regexp.MustCompile(`^(\d+)\.(\d+)\.\d+-dev`)
-	devMatches := devRe.FindStringSubmatch(version)
-	if len(devMatches) > 2 {
-		return fmt.Sprintf("%s.%s.0", devMatches[1], devMatches[2])
+	version, err := semver.ParseVersion(zigVersion)
+	if err != nil {
+		return false, err
 	}
-	return version
+
+	return constraint.Allows(version, true), nil
+}
+
+// DescribeZigRequirement phrases a zig_version for messages, e.g. "zig
+// 0.13.0 or newer".
+func DescribeZigRequirement(required string) string {
+	required = strings.TrimSpace(required)
+	if _, err := semver.ParseVersion(required); err == nil {
+		return fmt.Sprintf("zig %s or newer", required)
+	}
+	return "zig " + required
+}
+
+// ParseZigRequirement parses the zig_version of a package.
+func ParseZigRequirement(required string) (semver.ConstraintSet, error) {
+	required = strings.TrimSpace(required)
+	if _, err := semver.ParseVersion(required); err == nil {
+		required = ">=" + required
+	}
+
+	constraint, err := semver.ParseConstraint(required)
+	if err != nil {
+		return semver.ConstraintSet{}, fmt.Errorf("invalid zig_version '%s': %w", required, err)
+	}
+	return constraint, nil
 }
 
 func ValidateAndSanitizeRootFile(rootFile string) (string, error) {
@@ -0,0 +1,262 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yuki_zpm.org/logger"
+	"yuki_zpm.org/manifest"
+)
+
+// ErrNoManifest is returned when neither the directory nor a workspace
+// containing it has a yuki.toml.
+var ErrNoManifest = errors.New("no yuki.toml found")
+
+// Member is one package of a project.
+type Member struct {
+	Name     string
+	Dir      string
+	Manifest *manifest.Manifest
+}
+
+// Project is what a command operates on: a single package, or a workspace
+// whose members share one yuki.lock and one yuki_modules directory at Root.
+type Project struct {
+	Root      string
+	Workspace bool
+
+	// Members are all packages sharing the lock file. Selected are the ones
+	// the command was asked to work on, either with -p or by running it
+	// inside a member's directory.
+	Members  []Member
+	Selected []Member
+
+	// Patch is the [patch] table of the manifest at Root. Patches of other
+	// members are ignored.
+	Patch map[string]manifest.Dependency
+}
+
+// Load finds the project dir belongs to. selected names a workspace member,
+// by package name or directory, and may be empty.
+func Load(dir, selected string) (*Project, error) {
+	root, rootManifest, err := findWorkspaceRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootManifest == nil {
+		return loadPackage(dir, selected)
+	}
+
+	members, err := loadMembers(root, rootManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Project{Root: root, Workspace: true, Members: members, Patch: rootManifest.Patch}
+	for _, member := range members {
+		if len(member.Manifest.Patch) > 0 && member.Dir != root {
+			logger.Warn("[patch] in %s is ignored; patches belong in the workspace root's yuki.toml", member.Dir)
+		}
+	}
+
+	if selected != "" {
+		member, err := project.Member(selected)
+		if err != nil {
+			return nil, err
+		}
+		project.Selected = []Member{*member}
+		return project, nil
+	}
+
+	if !sameDir(dir, root) {
+		for _, member := range members {
+			if sameDir(dir, member.Dir) {
+				project.Selected = []Member{member}
+				return project, nil
+			}
+		}
+		// A package below the workspace root that is not one of its
+		// members is a project of its own.
+		if manifest.Exists(dir) {
+			return loadPackage(dir, selected)
+		}
+	}
+
+	project.Selected = members
+	return project, nil
+}
+
+func loadPackage(dir, selected string) (*Project, error) {
+	if !manifest.Exists(dir) {
+		return nil, ErrNoManifest
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	member := Member{Name: m.Package.Name, Dir: dir, Manifest: m}
+	if selected != "" && selected != member.Name {
+		return nil, fmt.Errorf("'%s' is not part of a workspace, so -p %s cannot be used", member.Name, selected)
+	}
+
+	return &Project{Root: dir, Members: []Member{member}, Selected: []Member{member}, Patch: m.Patch}, nil
+}
+
+// Member returns the member with the given package name or directory.
+func (p *Project) Member(name string) (*Member, error) {
+	for i := range p.Members {
+		if p.Members[i].Name == name {
+			return &p.Members[i], nil
+		}
+	}
+	for i := range p.Members {
+		if rel, err := filepath.Rel(p.Root, p.Members[i].Dir); err == nil && filepath.ToSlash(rel) == strings.TrimSuffix(filepath.ToSlash(name), "/") {
+			return &p.Members[i], nil
+		}
+	}
+
+	var names []string
+	for _, member := range p.Members {
+		names = append(names, member.Name)
+	}
+	return nil, fmt.Errorf("no workspace member named '%s' (members: %s)", name, strings.Join(names, ", "))
+}
+
+// Single returns the one package a command that edits a manifest works on.
+func (p *Project) Single() (*Member, error) {
+	if len(p.Selected) != 1 {
+		return nil, fmt.Errorf("this command works on a single package; choose a workspace member with -p <member>")
+	}
+	return &p.Selected[0], nil
+}
+
+// Dependencies returns the names of the dependencies of every member.
+func (p *Project) Dependencies() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, member := range p.Members {
+		for _, name := range member.Manifest.DependencyNames() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fingerprint returns the manifest.Fingerprint of every member's
+// dependency tables and the project's [patch] table.
+func (p *Project) Fingerprint() string {
+	manifests := make(map[string]*manifest.Manifest)
+	for _, member := range p.Members {
+		manifests[member.Name] = member.Manifest
+	}
+	return manifest.Fingerprint(manifests, p.Patch)
+}
+
+// findWorkspaceRoot walks up from dir looking for a yuki.toml with a
+// [workspace] table. It returns an empty root if there is none.
+func findWorkspaceRoot(dir string) (string, *manifest.Manifest, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for current := abs; ; current = filepath.Dir(current) {
+		if manifest.Exists(current) {
+			m, err := manifest.Load(current)
+			if err != nil {
+				return "", nil, err
+			}
+			if m.Workspace != nil {
+				root, err := filepath.Rel(abs, current)
+				if err != nil {
+					return "", nil, err
+				}
+				return filepath.Join(dir, root), m, nil
+			}
+		}
+
+		if filepath.Dir(current) == current {
+			return "", nil, nil
+		}
+	}
+}
+
+func loadMembers(root string, rootManifest *manifest.Manifest) ([]Member, error) {
+	excluded := make(map[string]bool)
+	for _, pattern := range rootManifest.Workspace.Exclude {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace exclude pattern '%s': %w", pattern, err)
+		}
+		for _, match := range matches {
+			excluded[filepath.Clean(match)] = true
+		}
+	}
+
+	var members []Member
+	seen := make(map[string]bool)
+
+	if rootManifest.Package.Name != "" {
+		members = append(members, Member{Name: rootManifest.Package.Name, Dir: root, Manifest: rootManifest})
+		seen[filepath.Clean(root)] = true
+	}
+
+	for _, pattern := range rootManifest.Workspace.Members {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace member pattern '%s': %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("workspace member '%s' does not exist", pattern)
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			match = filepath.Clean(match)
+			if seen[match] || excluded[match] {
+				continue
+			}
+			if info, err := os.Stat(match); err != nil || !info.IsDir() || !manifest.Exists(match) {
+				continue
+			}
+
+			m, err := manifest.Load(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load workspace member '%s': %w", match, err)
+			}
+			if m.Workspace != nil {
+				return nil, fmt.Errorf("workspace member '%s' cannot be a workspace itself", match)
+			}
+
+			seen[match] = true
+			members = append(members, Member{Name: m.Package.Name, Dir: match, Manifest: m})
+		}
+	}
+
+	names := make(map[string]string)
+	for _, member := range members {
+		if other, exists := names[member.Name]; exists {
+			return nil, fmt.Errorf("workspace members '%s' and '%s' are both named '%s'", other, member.Dir, member.Name)
+		}
+		names[member.Name] = member.Dir
+	}
+
+	return members, nil
+}
+
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}